@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"reflect"
+	"unsafe"
+
+	fuzz "github.com/google/gofuzz"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// discoverDefaultedGVKs determines, for every external GVK known to scheme,
+// whether it has a registered SetDefaults_* function, without maintaining a
+// hand-written list that silently drifts as defaulters are added or removed.
+//
+// This is deliberately not the same mechanism TestDefaulting later uses to
+// verify the result (fuzz the object and check scheme.Default changes it):
+// if discovery also worked by fuzzing, TestDefaulting's "every GVK that
+// mutates is in the discovered set" assertion would be tautological, since
+// discovery would just be re-deriving the exact signal being checked.
+// Instead, discovery reads scheme's real defaulterFuncs registry - the map
+// AddTypeDefaultingFunc populates and Default consults - via reflection,
+// since runtime.Scheme does not expose it through its public API. Only if
+// that field cannot be reached this way (e.g. a future apimachinery
+// internal rename) does discovery fall back to the weaker fuzz-until-mutated
+// signal; f and maxIterations exist for that fallback path.
+func discoverDefaultedGVKs(scheme *runtime.Scheme, f *fuzz.Fuzzer, maxIterations int) map[schema.GroupVersionKind]struct{} {
+	discovered := map[schema.GroupVersionKind]struct{}{}
+	registeredTypes, haveRegistry := registeredDefaulterFuncTypes(scheme)
+
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal {
+			continue
+		}
+
+		src, err := scheme.New(gvk)
+		if err != nil {
+			continue
+		}
+
+		if haveRegistry {
+			if registeredTypes[reflect.TypeOf(src)] {
+				discovered[gvk] = struct{}{}
+			}
+			continue
+		}
+
+		for i := 0; i < maxIterations; i++ {
+			f.Fuzz(src)
+			src.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+
+			original := src.DeepCopyObject()
+			withDefaults := src.DeepCopyObject()
+			scheme.Default(withDefaults.(runtime.Object))
+
+			if !reflect.DeepEqual(original, withDefaults) {
+				discovered[gvk] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return discovered
+}
+
+// registeredDefaulterFuncTypes reads scheme's unexported defaulterFuncs
+// registry via reflection, returning the set of concrete types that
+// currently have a SetDefaults_* function wired up via
+// AddTypeDefaultingFunc. ok is false if the field could not be found or
+// isn't shaped the way this expects, which callers should treat as "the
+// registry isn't readable this way any more", not "nothing is registered".
+func registeredDefaulterFuncTypes(scheme *runtime.Scheme) (types map[reflect.Type]bool, ok bool) {
+	defer func() {
+		if recover() != nil {
+			types, ok = nil, false
+		}
+	}()
+
+	field := reflect.ValueOf(scheme).Elem().FieldByName("defaulterFuncs")
+	if !field.IsValid() || field.Kind() != reflect.Map {
+		return nil, false
+	}
+	// field is unexported, so field.Interface()/MapKeys() would normally
+	// panic; re-deriving a Value at the same address strips that
+	// restriction without mutating anything.
+	field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+
+	types = make(map[reflect.Type]bool, field.Len())
+	for _, key := range field.MapKeys() {
+		t, isType := key.Interface().(reflect.Type)
+		if !isType {
+			return nil, false
+		}
+		types[t] = true
+	}
+	return types, true
+}
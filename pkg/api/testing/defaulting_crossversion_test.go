@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+)
+
+// crossVersionKind names a Kind that is defaulted independently at each of
+// its external versions, along with the internal GroupVersion defaulting
+// should converge to once conversion is applied.
+type crossVersionKind struct {
+	internal schema.GroupVersion
+	kind     string
+	versions []string
+
+	// ignoredFields lists internal-object field paths (as produced by
+	// cmp.Diff's Path().String()) that are allowed to differ across
+	// versions - e.g. a field that only exists starting at a later version.
+	ignoredFields []string
+}
+
+var crossVersionKinds = []crossVersionKind{
+	{
+		internal: schema.GroupVersion{Group: "apps", Version: runtime.APIVersionInternal},
+		kind:     "Deployment",
+		versions: []string{"v1beta1", "v1beta2", "v1"},
+	},
+	{
+		internal: schema.GroupVersion{Group: "autoscaling", Version: runtime.APIVersionInternal},
+		kind:     "HorizontalPodAutoscaler",
+		versions: []string{"v1", "v2beta1", "v2beta2", "v2"},
+	},
+	{
+		internal: schema.GroupVersion{Group: "batch", Version: runtime.APIVersionInternal},
+		kind:     "CronJob",
+		versions: []string{"v1beta1", "v1"},
+	},
+}
+
+// TestCrossVersionDefaultingEquivalence fuzzes a single source value per
+// Kind, then independently round-trips it through each registered external
+// version, defaulting at that version, and converts the result to the
+// internal version. If a defaulter was added for one version (say v1) and
+// never ported to an older one still served by the cluster (say v1beta2),
+// the two resulting internal objects disagree and this test catches it -
+// the kind of gap that otherwise only surfaces as an upgrade regression.
+func TestCrossVersionDefaultingEquivalence(t *testing.T) {
+	scheme := legacyscheme.Scheme
+	f := newDefaultingFuzzer()
+
+	for _, cvk := range crossVersionKinds {
+		t.Run(cvk.kind, func(t *testing.T) {
+			internalGVK := cvk.internal.WithKind(cvk.kind)
+			source, err := scheme.New(internalGVK)
+			if err != nil {
+				t.Skipf("internal kind %s not registered in this build: %v", internalGVK, err)
+			}
+			f.Fuzz(source)
+			source.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+
+			var reference runtime.Object
+			for i, version := range cvk.versions {
+				internalResult := defaultAtVersionThenConvertToInternal(t, scheme, source, cvk.internal.WithVersion(version).WithKind(cvk.kind), internalGVK)
+				if i == 0 {
+					reference = internalResult
+					continue
+				}
+				if diff := diffIgnoringFields(reference, internalResult, cvk.ignoredFields); diff != "" {
+					t.Errorf("defaulting %s at version %q disagrees with version %q after conversion to internal: %s", cvk.kind, version, cvk.versions[0], diff)
+				}
+			}
+		})
+	}
+}
+
+// defaultAtVersionThenConvertToInternal converts source (an internal object)
+// to externalGVK, defaults it there, then converts the defaulted value back
+// to internalGVK.
+func defaultAtVersionThenConvertToInternal(t *testing.T, scheme *runtime.Scheme, source runtime.Object, externalGVK, internalGVK schema.GroupVersionKind) runtime.Object {
+	t.Helper()
+
+	external, err := scheme.New(externalGVK)
+	if err != nil {
+		t.Fatalf("version %s not registered: %v", externalGVK, err)
+	}
+	if err := scheme.Convert(source, external, nil); err != nil {
+		t.Fatalf("converting to %s: %v", externalGVK, err)
+	}
+	scheme.Default(external)
+
+	internal, err := scheme.New(internalGVK)
+	if err != nil {
+		t.Fatalf("internal kind %s not registered: %v", internalGVK, err)
+	}
+	if err := scheme.Convert(external, internal, nil); err != nil {
+		t.Fatalf("converting %s back to internal: %v", externalGVK, err)
+	}
+	return internal
+}
+
+func diffIgnoringFields(a, b runtime.Object, ignoredFields []string) string {
+	opts := make([]cmp.Option, 0, len(ignoredFields))
+	for _, path := range ignoredFields {
+		path := path
+		opts = append(opts, cmp.FilterPath(func(p cmp.Path) bool { return p.String() == path }, cmp.Ignore()))
+	}
+	if reflect.DeepEqual(a, b) {
+		return ""
+	}
+	return cmp.Diff(a, b, opts...)
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	openapi_v2 "github.com/googleapis/gnostic/openapiv2"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+)
+
+// openAPISwaggerFixture is a trimmed stand-in for the full published
+// api/openapi-spec/swagger.json. It carries just enough "default:" bearing
+// properties to exercise the audit below; in a full checkout this test
+// would point at the real generated document instead.
+const openAPISwaggerFixture = "testdata/openapi/swagger.json"
+
+// openAPIDefault records one schema-declared default extracted from the
+// OpenAPI document, keyed by the owning GVK and the JSON path of the field
+// within it.
+type openAPIDefault struct {
+	gvk       schema.GroupVersionKind
+	fieldPath string
+	value     interface{}
+}
+
+// TestOpenAPIDefaultingAudit walks every OpenAPI schema property that
+// declares a "default", constructs a zero-valued object of the owning GVK
+// with that field left unset, runs scheme.Default, and asserts the
+// schema-declared default was actually applied. This turns the published
+// API contract into an executable check so drift between the documented
+// default and what the server actually does is caught here rather than by
+// a confused user filing a bug.
+func TestOpenAPIDefaultingAudit(t *testing.T) {
+	raw, err := os.ReadFile(openAPISwaggerFixture)
+	if err != nil {
+		t.Skipf("no OpenAPI document available to audit: %v", err)
+	}
+
+	doc, err := openapi_v2.ParseDocument(raw)
+	if err != nil {
+		t.Fatalf("parsing OpenAPI document: %v", err)
+	}
+
+	defaults := extractDefaults(doc)
+	if len(defaults) == 0 {
+		t.Fatal("expected at least one schema-declared default in the fixture document")
+	}
+
+	scheme := legacyscheme.Scheme
+	for _, d := range defaults {
+		t.Run(d.gvk.String()+d.fieldPath, func(t *testing.T) {
+			obj, err := scheme.New(d.gvk)
+			if err != nil {
+				t.Skipf("GVK %s not registered in this build: %v", d.gvk, err)
+			}
+
+			data, err := json.Marshal(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var asMap map[string]interface{}
+			if err := json.Unmarshal(data, &asMap); err != nil {
+				t.Fatal(err)
+			}
+			deleteJSONPath(asMap, d.fieldPath)
+			data, err = json.Marshal(asMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, obj); err != nil {
+				t.Fatal(err)
+			}
+
+			scheme.Default(obj)
+
+			data, err = json.Marshal(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			asMap = nil
+			if err := json.Unmarshal(data, &asMap); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := lookupJSONPath(asMap, d.fieldPath)
+			if !ok {
+				t.Errorf("field %s was not set by defaulting, but OpenAPI declares default %v", d.fieldPath, d.value)
+				return
+			}
+			if got != d.value {
+				t.Errorf("field %s defaulted to %v, but OpenAPI declares default %v", d.fieldPath, got, d.value)
+			}
+		})
+	}
+}
+
+// extractDefaults walks every definition in doc that itself carries an
+// x-kubernetes-group-version-kind extension (the real generated document
+// puts that extension on the Kind's own schema, e.g.
+// io.k8s.api.core.v1.Service, not on nested embedded types like
+// ServiceSpec), then walks that schema's properties - following $ref into
+// embedded definitions - recording each schema-declared "default" by its
+// full dotted path from the Kind's root object (e.g. "spec.sessionAffinity"
+// for Service, whose sessionAffinity default is actually declared on the
+// embedded ServiceSpec).
+func extractDefaults(doc *openapi_v2.Document) []openAPIDefault {
+	var out []openAPIDefault
+	if doc == nil || doc.Definitions == nil {
+		return out
+	}
+
+	byName := make(map[string]*openapi_v2.Schema, len(doc.Definitions.AdditionalProperties))
+	for _, namedSchema := range doc.Definitions.AdditionalProperties {
+		byName[namedSchema.Name] = namedSchema.Value
+	}
+
+	for _, namedSchema := range doc.Definitions.AdditionalProperties {
+		def := namedSchema.Value
+		if def == nil {
+			continue
+		}
+		gvks := groupVersionKindsOf(def)
+		if len(gvks) == 0 {
+			continue
+		}
+		for _, pd := range collectDefaults(def, byName, "", map[string]bool{namedSchema.Name: true}) {
+			for _, gvk := range gvks {
+				out = append(out, openAPIDefault{gvk: gvk, fieldPath: pd.fieldPath, value: pd.value})
+			}
+		}
+	}
+	return out
+}
+
+// pathDefault is a schema-declared default recorded during the walk in
+// collectDefaults, before it's paired up with the owning GVK(s).
+type pathDefault struct {
+	fieldPath string
+	value     interface{}
+}
+
+// collectDefaults recursively walks schema's properties, following $ref into
+// byName, and records a pathDefault for every property carrying a "default"
+// - each path prefixed so it is relative to the root schema extractDefaults
+// started from, not just the definition that happens to declare it. visited
+// guards against cyclic $ref chains.
+func collectDefaults(schema *openapi_v2.Schema, byName map[string]*openapi_v2.Schema, prefix string, visited map[string]bool) []pathDefault {
+	if schema == nil || schema.Properties == nil {
+		return nil
+	}
+
+	var out []pathDefault
+	for _, namedProp := range schema.Properties.AdditionalProperties {
+		prop := namedProp.Value
+		if prop == nil {
+			continue
+		}
+		path := namedProp.Name
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if prop.Default != nil {
+			out = append(out, pathDefault{fieldPath: path, value: defaultValueOf(prop)})
+		}
+
+		ref := refName(prop)
+		if ref == "" || visited[ref] {
+			continue
+		}
+		nested, ok := byName[ref]
+		if !ok {
+			continue
+		}
+		nextVisited := make(map[string]bool, len(visited)+1)
+		for k := range visited {
+			nextVisited[k] = true
+		}
+		nextVisited[ref] = true
+		out = append(out, collectDefaults(nested, byName, path, nextVisited)...)
+	}
+	return out
+}
+
+// refName extracts the bare definition name a schema property's $ref points
+// at, e.g. "io.k8s.api.core.v1.ServiceSpec" from
+// "#/definitions/io.k8s.api.core.v1.ServiceSpec".
+func refName(prop *openapi_v2.Schema) string {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(prop.XRef, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(prop.XRef, prefix)
+}
+
+// deleteJSONPath removes the field at path (dot-separated, e.g.
+// "spec.sessionAffinity") from m, descending into nested object values. A
+// path segment that doesn't resolve to a nested object is a no-op rather
+// than a panic, since the audit should report a missing default, not crash.
+func deleteJSONPath(m map[string]interface{}, path string) {
+	segments := strings.Split(path, ".")
+	cur := m
+	for i, seg := range segments {
+		if i == len(segments)-1 {
+			delete(cur, seg)
+			return
+		}
+		next, ok := cur[seg].(map[string]interface{})
+		if !ok {
+			return
+		}
+		cur = next
+	}
+}
+
+// lookupJSONPath reads the field at path (dot-separated) out of m, the same
+// way deleteJSONPath navigates to it, reporting ok=false if any segment
+// along the way is absent or not an object.
+func lookupJSONPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = m
+	segments := strings.Split(path, ".")
+	for i, seg := range segments {
+		asMap, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := asMap[seg]
+		if !ok {
+			return nil, false
+		}
+		if i == len(segments)-1 {
+			return v, true
+		}
+		cur = v
+	}
+	return nil, false
+}
+
+// groupVersionKindsOf reads the x-kubernetes-group-version-kind vendor
+// extension off an OpenAPI schema property, returning the GVKs it applies
+// to (a property can be shared by more than one Kind in the real document).
+func groupVersionKindsOf(prop *openapi_v2.Schema) []schema.GroupVersionKind {
+	raw, ok := vendorExtension(prop, "x-kubernetes-group-version-kind")
+	if !ok {
+		return nil
+	}
+	var entries []struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	}
+	if err := sigsyaml.Unmarshal(raw, &entries); err != nil {
+		return nil
+	}
+	gvks := make([]schema.GroupVersionKind, 0, len(entries))
+	for _, e := range entries {
+		gvks = append(gvks, schema.GroupVersionKind{Group: e.Group, Version: e.Version, Kind: e.Kind})
+	}
+	return gvks
+}
+
+// defaultValueOf decodes the literal carried by a schema property's
+// "default" entry into a plain Go value comparable with what json.Marshal
+// would produce for the same field (e.g. "None" -> string, 0 -> float64).
+func defaultValueOf(prop *openapi_v2.Schema) interface{} {
+	if prop.Default == nil {
+		return nil
+	}
+	var v interface{}
+	if err := sigsyaml.Unmarshal([]byte(prop.Default.Yaml), &v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// vendorExtension looks up a named x-* extension on an OpenAPI schema
+// property and returns its raw YAML bytes.
+func vendorExtension(prop *openapi_v2.Schema, name string) ([]byte, bool) {
+	for _, ext := range prop.VendorExtension {
+		if ext.Name == name && ext.Value != nil {
+			return []byte(ext.Value.Yaml), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package disruption
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/features"
+)
+
+const (
+	coordinatorLeaseName = "disruption-controller-eviction-coordinator"
+	coordinatorLeaseNS   = "kube-system"
+)
+
+// evictionCoordinatorLeaseDuration and friends match the defaults used
+// elsewhere in the control plane (e.g. kube-controller-manager) for leader
+// election of singleton subsystems.
+const (
+	evictionCoordinatorLeaseDuration = 15 * time.Second
+	evictionCoordinatorRenewDeadline = 10 * time.Second
+	evictionCoordinatorRetryPeriod   = 2 * time.Second
+)
+
+// evictionCoordinatorGrantQueueSize bounds how many Grant calls can be
+// waiting on the lease holder's serving goroutine at once; callers beyond
+// this fall back to reserving directly rather than blocking indefinitely.
+const evictionCoordinatorGrantQueueSize = 256
+
+// maybeRunEvictionCoordinator starts the leader-elected eviction coordinator
+// in the background when the EvictionCoordinator feature gate is enabled. It
+// is a no-op otherwise, so DisruptionController.Run behaves exactly as
+// before when the gate is off.
+//
+// While leading, this instance is the sole server for Grant calls made by
+// callers on the same replica: rather than every local Evict()/evict-batch
+// caller racing the others with independent optimistic-concurrency retries
+// against the PDB, they're serialized through serveGrantQueue, one
+// ReserveDisruptionsUpTo call at a time. This is scoped to one process -
+// Grant does not forward requests received by a non-leading replica across
+// the network to whoever is leading, since no such RPC path exists here, so
+// callers that land on a different, non-leading replica still race it with
+// RetryOnConflict the same as if the coordinator were disabled.
+func (dc *DisruptionController) maybeRunEvictionCoordinator(ctx context.Context, identity string) {
+	if !feature.DefaultFeatureGate.Enabled(features.EvictionCoordinator) {
+		return
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      coordinatorLeaseName,
+			Namespace: coordinatorLeaseNS,
+		},
+		Client: dc.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	go leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   evictionCoordinatorLeaseDuration,
+		RenewDeadline:   evictionCoordinatorRenewDeadline,
+		RetryPeriod:     evictionCoordinatorRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				klog.InfoS("Became leader, serving as the eviction coordinator", "identity", identity)
+				dc.serveGrantQueue(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				klog.InfoS("Stopped serving as the eviction coordinator", "identity", identity)
+				dc.setGrantQueue(nil)
+			},
+		},
+	})
+}
+
+// serveGrantQueue installs a fresh grant queue and serves it from a single
+// goroutine, one ReserveDisruptionsUpTo call at a time, until leaderCtx is
+// done (i.e. this instance loses the lease). It returns once the queue is
+// installed; serving continues in the background.
+func (dc *DisruptionController) serveGrantQueue(leaderCtx context.Context) {
+	queue := make(chan *grantRequest, evictionCoordinatorGrantQueueSize)
+	dc.setGrantQueue(queue)
+
+	go func() {
+		for {
+			select {
+			case req := <-queue:
+				granted, err := dc.ReserveDisruptionsUpTo(req.namespace, req.name, req.requested)
+				req.result <- grantResult{granted: granted, err: err}
+			case <-leaderCtx.Done():
+				return
+			}
+		}
+	}()
+}
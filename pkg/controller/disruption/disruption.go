@@ -0,0 +1,502 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package disruption implements the PodDisruptionBudget controller, which
+// keeps PodDisruptionBudget.Status in sync with the pods it selects so that
+// the eviction subresource handler (pkg/registry/core/pod/storage) can admit
+// or reject voluntary disruptions without recomputing pod health itself.
+package disruption
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	appsv1informers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	policyinformers "k8s.io/client-go/informers/policy/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// DeletionTimeout sets maximum time from the moment a pod is added to DisruptedPods
+// in PDB.Status to the time when the pod is expected to be seen by PDB controller
+// as having been marked for deletion (or already deleted).
+const DeletionTimeout = 2 * time.Minute
+
+// DisruptionController watches PodDisruptionBudgets and the pods, replica
+// sets, deployments, replication controllers and stateful sets that they
+// cover, and keeps each PDB's Status up to date so that admission can make
+// synchronous decisions about whether an eviction is currently allowed.
+type DisruptionController struct {
+	kubeClient clientset.Interface
+	mapper     *restmapper.DeferredDiscoveryRESTMapper
+
+	scaleNamespacer scale.ScalesGetter
+	discoveryClient discovery.DiscoveryInterface
+
+	pdbLister       policylisters.PodDisruptionBudgetLister
+	pdbListerSynced cache.InformerSynced
+
+	podLister       corelisters.PodLister
+	podListerSynced cache.InformerSynced
+
+	rcLister       corelisters.ReplicationControllerLister
+	rcListerSynced cache.InformerSynced
+
+	rsLister       appsv1listers.ReplicaSetLister
+	rsListerSynced cache.InformerSynced
+
+	dLister       appsv1listers.DeploymentLister
+	dListerSynced cache.InformerSynced
+
+	ssLister       appsv1listers.StatefulSetLister
+	ssListerSynced cache.InformerSynced
+
+	queue        workqueue.RateLimitingInterface
+	recheckQueue workqueue.DelayingInterface
+
+	// grantQueueMu guards grantQueue, which is non-nil only while this
+	// DisruptionController instance holds the eviction coordinator lease.
+	// See Grant and maybeRunEvictionCoordinator.
+	grantQueueMu sync.Mutex
+	grantQueue   chan *grantRequest
+}
+
+// grantRequest is one Grant call waiting to be served by the eviction
+// coordinator's single serving goroutine.
+type grantRequest struct {
+	namespace, name string
+	requested       int32
+	result          chan grantResult
+}
+
+// grantResult is the outcome of a grantRequest, mirroring
+// ReserveDisruptionsUpTo's return values.
+type grantResult struct {
+	granted int32
+	err     error
+}
+
+// setGrantQueue installs (or, with a nil queue, tears down) the channel that
+// Grant funnels requests through. Called by maybeRunEvictionCoordinator as
+// this instance gains or loses the coordinator lease.
+func (dc *DisruptionController) setGrantQueue(queue chan *grantRequest) {
+	dc.grantQueueMu.Lock()
+	defer dc.grantQueueMu.Unlock()
+	dc.grantQueue = queue
+}
+
+func (dc *DisruptionController) currentGrantQueue() chan *grantRequest {
+	dc.grantQueueMu.Lock()
+	defer dc.grantQueueMu.Unlock()
+	return dc.grantQueue
+}
+
+// Grant reserves up to requested disruptions against the named
+// PodDisruptionBudget, the same way ReserveDisruptionsUpTo does, but when
+// this instance currently holds the eviction coordinator lease the request
+// is funneled through that lease holder's single serving goroutine instead
+// of racing other local callers with independent RetryOnConflict loops. When
+// no instance is leading (the EvictionCoordinator feature gate is off, or
+// leader election hasn't settled yet), Grant falls back to calling
+// ReserveDisruptionsUpTo directly, which is exactly what every replica
+// already did before this method existed.
+//
+// Grant only serializes requests handled by whichever apiserver process is
+// currently leading; it does not forward requests received by a non-leading
+// replica across the network to the leader, so two different apiserver
+// processes can still race each other's UpdateStatus calls and fall back to
+// RetryOnConflict the same as before. Funneling every replica's requests to
+// a single leader would require an RPC path from the eviction subresource
+// handler to a remote coordinator that does not exist in this codebase.
+func (dc *DisruptionController) Grant(namespace, name string, requested int32) (int32, error) {
+	queue := dc.currentGrantQueue()
+	if queue == nil {
+		return dc.ReserveDisruptionsUpTo(namespace, name, requested)
+	}
+
+	req := &grantRequest{namespace: namespace, name: name, requested: requested, result: make(chan grantResult, 1)}
+	select {
+	case queue <- req:
+	default:
+		// The queue's serving goroutine is saturated or shutting down; don't
+		// block the caller on a lease transition, just reserve directly.
+		return dc.ReserveDisruptionsUpTo(namespace, name, requested)
+	}
+
+	res := <-req.result
+	return res.granted, res.err
+}
+
+// NewDisruptionController creates a disruption controller that watches the
+// given informers and keeps PodDisruptionBudget status up to date.
+func NewDisruptionController(
+	podInformer coreinformers.PodInformer,
+	pdbInformer policyinformers.PodDisruptionBudgetInformer,
+	rcInformer coreinformers.ReplicationControllerInformer,
+	rsInformer appsv1informers.ReplicaSetInformer,
+	dInformer appsv1informers.DeploymentInformer,
+	ssInformer appsv1informers.StatefulSetInformer,
+	kubeClient clientset.Interface,
+	restMapper *restmapper.DeferredDiscoveryRESTMapper,
+	scaleNamespacer scale.ScalesGetter,
+	discoveryClient discovery.DiscoveryInterface,
+) *DisruptionController {
+	dc := &DisruptionController{
+		kubeClient:      kubeClient,
+		mapper:          restMapper,
+		scaleNamespacer: scaleNamespacer,
+		discoveryClient: discoveryClient,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "disruption"),
+		recheckQueue:    workqueue.NewNamedDelayingQueue("disruption-recheck"),
+	}
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    dc.addPod,
+		UpdateFunc: dc.updatePod,
+		DeleteFunc: dc.deletePod,
+	})
+	dc.podLister = podInformer.Lister()
+	dc.podListerSynced = podInformer.Informer().HasSynced
+
+	pdbInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    dc.addDB,
+		UpdateFunc: dc.updateDB,
+		DeleteFunc: dc.removeDB,
+	})
+	dc.pdbLister = pdbInformer.Lister()
+	dc.pdbListerSynced = pdbInformer.Informer().HasSynced
+
+	dc.rcLister = rcInformer.Lister()
+	dc.rcListerSynced = rcInformer.Informer().HasSynced
+
+	dc.rsLister = rsInformer.Lister()
+	dc.rsListerSynced = rsInformer.Informer().HasSynced
+
+	dc.dLister = dInformer.Lister()
+	dc.dListerSynced = dInformer.Informer().HasSynced
+
+	dc.ssLister = ssInformer.Lister()
+	dc.ssListerSynced = ssInformer.Informer().HasSynced
+
+	return dc
+}
+
+// Run starts the controller and blocks until ctx is done.
+func (dc *DisruptionController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer dc.queue.ShutDown()
+	defer dc.recheckQueue.ShutDown()
+
+	klog.InfoS("Starting disruption controller")
+	defer klog.InfoS("Shutting down disruption controller")
+
+	if !cache.WaitForNamedCacheSync("disruption", ctx.Done(),
+		dc.podListerSynced,
+		dc.pdbListerSynced,
+		dc.rcListerSynced,
+		dc.rsListerSynced,
+		dc.dListerSynced,
+		dc.ssListerSynced,
+	) {
+		return
+	}
+
+	go wait.Until(dc.worker, time.Second, ctx.Done())
+	go wait.Until(dc.recheckWorker, time.Second, ctx.Done())
+	dc.maybeRunEvictionCoordinator(ctx, uuid.New().String())
+
+	<-ctx.Done()
+}
+
+func (dc *DisruptionController) worker() {
+	for dc.processNextWorkItem() {
+	}
+}
+
+func (dc *DisruptionController) recheckWorker() {
+	for dc.processNextRecheckWorkItem() {
+	}
+}
+
+func (dc *DisruptionController) processNextWorkItem() bool {
+	key, quit := dc.queue.Get()
+	if quit {
+		return false
+	}
+	defer dc.queue.Done(key)
+
+	err := dc.sync(key.(string))
+	if err == nil {
+		dc.queue.Forget(key)
+		return true
+	}
+
+	klog.ErrorS(err, "Error syncing PodDisruptionBudget, requeueing", "key", key)
+	dc.queue.AddRateLimited(key)
+	return true
+}
+
+func (dc *DisruptionController) processNextRecheckWorkItem() bool {
+	key, quit := dc.recheckQueue.Get()
+	if quit {
+		return false
+	}
+	defer dc.recheckQueue.Done(key)
+	dc.queue.AddRateLimited(key)
+	return true
+}
+
+func (dc *DisruptionController) addPod(obj interface{})         { dc.enqueuePdbForPod(obj) }
+func (dc *DisruptionController) updatePod(old, cur interface{}) { dc.enqueuePdbForPod(cur) }
+func (dc *DisruptionController) deletePod(obj interface{})      { dc.enqueuePdbForPod(obj) }
+
+func (dc *DisruptionController) enqueuePdbForPod(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	pdb, err := dc.findPdbForPod(pod)
+	if err != nil || pdb == nil {
+		return
+	}
+	dc.enqueuePdb(pdb)
+}
+
+func (dc *DisruptionController) findPdbForPod(pod *v1.Pod) (*policy.PodDisruptionBudget, error) {
+	pdbs, err := dc.pdbLister.GetPodPodDisruptionBudgets(pod)
+	if err != nil {
+		return nil, err
+	}
+	if len(pdbs) == 0 {
+		return nil, nil
+	}
+	return pdbs[0], nil
+}
+
+func (dc *DisruptionController) addDB(obj interface{}) {
+	dc.enqueuePdb(obj.(*policy.PodDisruptionBudget))
+}
+func (dc *DisruptionController) updateDB(old, cur interface{}) {
+	dc.enqueuePdb(cur.(*policy.PodDisruptionBudget))
+}
+func (dc *DisruptionController) removeDB(obj interface{}) {
+	if pdb, ok := obj.(*policy.PodDisruptionBudget); ok {
+		dc.enqueuePdb(pdb)
+	}
+}
+
+func (dc *DisruptionController) enqueuePdb(pdb *policy.PodDisruptionBudget) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(pdb)
+	if err != nil {
+		klog.ErrorS(err, "Couldn't get key for PodDisruptionBudget", "object", pdb)
+		return
+	}
+	dc.queue.Add(key)
+}
+
+// ReserveDisruptions atomically reserves count disruptions against the named
+// PodDisruptionBudget, decrementing DisruptionsAllowed by count in a single
+// status update. It is the primitive a batch-eviction caller uses to admit
+// or reject K pod evictions against a single PDB with one conflict-retry
+// loop, instead of each pod racing the others through its own
+// read-decrement-write cycle.
+//
+// It returns an error satisfying apierrors.IsTooManyRequests if fewer than
+// count disruptions are currently allowed; the budget is left unmodified in
+// that case.
+func (dc *DisruptionController) ReserveDisruptions(namespace, name string, count int32) error {
+	if count <= 0 {
+		return fmt.Errorf("count must be positive, got %d", count)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		pdb, err := dc.kubeClient.PolicyV1().PodDisruptionBudgets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if pdb.Status.DisruptionsAllowed < count {
+			return apierrors.NewTooManyRequests(fmt.Sprintf(
+				"PodDisruptionBudget %s/%s only allows %d disruptions, %d requested",
+				namespace, name, pdb.Status.DisruptionsAllowed, count), 0)
+		}
+
+		newPdb := pdb.DeepCopy()
+		newPdb.Status.DisruptionsAllowed -= count
+		_, err = dc.kubeClient.PolicyV1().PodDisruptionBudgets(namespace).UpdateStatus(context.TODO(), newPdb, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// ReserveDisruptionsUpTo atomically reserves as many as requested
+// disruptions against the named PodDisruptionBudget, up to whatever
+// DisruptionsAllowed currently permits - which may be fewer than requested
+// (including zero), rather than failing outright. It is the primitive a
+// partial-admission batch-eviction caller uses to admit min(requested,
+// allowed) pods against a single PDB with one conflict-retry loop.
+//
+// granted is always in [0, requested] and is only ever non-zero alongside a
+// nil error.
+func (dc *DisruptionController) ReserveDisruptionsUpTo(namespace, name string, requested int32) (granted int32, err error) {
+	if requested <= 0 {
+		return 0, fmt.Errorf("requested must be positive, got %d", requested)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		pdb, getErr := dc.kubeClient.PolicyV1().PodDisruptionBudgets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+
+		granted = requested
+		if pdb.Status.DisruptionsAllowed < granted {
+			granted = pdb.Status.DisruptionsAllowed
+		}
+		if granted <= 0 {
+			granted = 0
+			return nil
+		}
+
+		newPdb := pdb.DeepCopy()
+		newPdb.Status.DisruptionsAllowed -= granted
+		_, updateErr := dc.kubeClient.PolicyV1().PodDisruptionBudgets(namespace).UpdateStatus(context.TODO(), newPdb, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if err != nil {
+		return 0, err
+	}
+	return granted, nil
+}
+
+func (dc *DisruptionController) sync(key string) error {
+	startTime := time.Now()
+	defer func() {
+		klog.V(4).InfoS("Finished syncing PodDisruptionBudget", "key", key, "duration", time.Since(startTime))
+	}()
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	pdb, err := dc.pdbLister.PodDisruptionBudgets(namespace).Get(name)
+	if err != nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return err
+	}
+
+	pods, err := dc.podLister.Pods(pdb.Namespace).List(selector)
+	if err != nil {
+		return err
+	}
+
+	currentHealthy := countHealthyPods(pods)
+	desiredHealthy, expectedCount, err := dc.getExpectedPodCount(pdb, pods)
+	if err != nil {
+		return err
+	}
+
+	return dc.updatePdbStatus(pdb, currentHealthy, desiredHealthy, expectedCount, selector)
+}
+
+func countHealthyPods(pods []*v1.Pod) int32 {
+	var healthy int32
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if podutilIsReady(pod) {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+func podutilIsReady(pod *v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// getExpectedPodCount returns the desired healthy count (derived from
+// pdb.Spec.MinAvailable/MaxUnavailable) and the expected pod count, which is
+// the number of pods the PDB's selector currently matches (the owning
+// controller's replica count is not consulted).
+func (dc *DisruptionController) getExpectedPodCount(pdb *policy.PodDisruptionBudget, pods []*v1.Pod) (desiredHealthy, expectedCount int32, err error) {
+	expectedCount = int32(len(pods))
+	if pdb.Spec.MinAvailable != nil {
+		min, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(expectedCount), true)
+		return int32(min), expectedCount, err
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(expectedCount), true)
+		if err != nil {
+			return 0, expectedCount, err
+		}
+		return expectedCount - int32(maxUnavailable), expectedCount, nil
+	}
+	return 0, expectedCount, fmt.Errorf("PodDisruptionBudget %s/%s has neither MinAvailable nor MaxUnavailable", pdb.Namespace, pdb.Name)
+}
+
+func (dc *DisruptionController) updatePdbStatus(pdb *policy.PodDisruptionBudget, currentHealthy, desiredHealthy, expectedCount int32, selector labels.Selector) error {
+	disruptionsAllowed := currentHealthy - desiredHealthy
+	if disruptionsAllowed < 0 {
+		disruptionsAllowed = 0
+	}
+
+	newStatus := pdb.Status.DeepCopy()
+	newStatus.CurrentHealthy = currentHealthy
+	newStatus.DesiredHealthy = desiredHealthy
+	newStatus.ExpectedPods = expectedCount
+	newStatus.DisruptionsAllowed = disruptionsAllowed
+
+	if apiequality.Semantic.DeepEqual(&pdb.Status, newStatus) {
+		return nil
+	}
+
+	newStatus.ObservedGeneration = pdb.Generation
+	newPdb := pdb.DeepCopy()
+	newPdb.Status = *newStatus
+	_, err := dc.kubeClient.PolicyV1().PodDisruptionBudgets(pdb.Namespace).UpdateStatus(context.TODO(), newPdb, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"k8s.io/apiserver/pkg/util/feature"
+	featuregate "k8s.io/component-base/featuregate"
+)
+
+const (
+	// owner: @mortent, @krmayankk
+	// beta: v1.22
+	//
+	// Enables the PodDisruptionBudget spec.minAvailable field to be used with
+	// pod-level disruption conditions, marking evicted pods with a
+	// DisruptionTarget pod condition describing the reason for disruption.
+	PodDisruptionConditions featuregate.Feature = "PodDisruptionConditions"
+
+	// owner: @mortent
+	// alpha: v1.29
+	//
+	// Enables pkg/controller/disruption's optional leader-elected eviction
+	// coordinator, which funnels concurrent Evict calls for a given
+	// PodDisruptionBudget through a single writer instead of relying on
+	// optimistic retry-on-conflict.
+	EvictionCoordinator featuregate.Feature = "EvictionCoordinator"
+)
+
+// defaultKubernetesFeatureGates consists of all known Kubernetes-specific
+// feature keys. To add a new feature, define a key for it above and add it
+// here.
+var defaultKubernetesFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	PodDisruptionConditions: {Default: true, PreRelease: featuregate.Beta},
+	EvictionCoordinator:     {Default: false, PreRelease: featuregate.Alpha},
+}
+
+func init() {
+	utilruntimeMustAddFeatures()
+}
+
+func utilruntimeMustAddFeatures() {
+	if err := feature.DefaultMutableFeatureGate.Add(defaultKubernetesFeatureGates); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/feature"
+	policyv1listers "k8s.io/client-go/listers/policy/v1"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+	"k8s.io/kubernetes/pkg/controller/disruption"
+	"k8s.io/kubernetes/pkg/features"
+)
+
+// PreventEvictionAnnotation lets an operator mark an individual pod as
+// ineligible for voluntary disruption (drains, descheduler, cluster
+// autoscaler) while still allowing it to be deleted directly. It is a
+// "break-glass" escape hatch for pods that a PDB alone cannot protect,
+// mirroring the pattern used by external tainted-pod evicters.
+const PreventEvictionAnnotation = "pod.kubernetes.io/prevent-eviction"
+
+// EvictionReasonAnnotation and EvictionMessageAnnotation let the caller of
+// the eviction subresource attach a structured reason/message that is
+// propagated into the pod's DisruptionTarget condition, so that downstream
+// controllers and audit consumers can distinguish *why* a pod was evicted
+// without having to infer it from the evicting client's identity.
+const (
+	EvictionReasonAnnotation  = "eviction.k8s.io/reason"
+	EvictionMessageAnnotation = "eviction.k8s.io/message"
+
+	maxEvictionMessageLength = 1024
+)
+
+// Well-known eviction reasons. EvictionReasonAnnotation is not restricted to
+// this list - any well-formed reason is accepted - but these are the values
+// well-behaved, in-tree-adjacent callers are expected to use.
+const (
+	EvictionReasonNodeDrain   = "NodeDrain"
+	EvictionReasonDescheduler = "Descheduler"
+	EvictionReasonAutoscaler  = "Autoscaler"
+	EvictionReasonAdminEvict  = "AdminEvict"
+)
+
+// EvictionREST implements the eviction subresource for pods.
+type EvictionREST struct {
+	store                     *genericregistry.Store
+	podDisruptionBudgetLister policyv1listers.PodDisruptionBudgetLister
+	coordinator               *disruption.DisruptionController
+}
+
+var _ = rest.NamedCreater(&EvictionREST{})
+
+// newEvictionStorage returns an EvictionREST subresource backed by the given
+// pod store and PDB lister, using coordinator to atomically reserve PDB
+// budget for each eviction.
+func newEvictionStorage(store *genericregistry.Store, podDisruptionBudgetLister policyv1listers.PodDisruptionBudgetLister, coordinator *disruption.DisruptionController) *EvictionREST {
+	return &EvictionREST{store: store, podDisruptionBudgetLister: podDisruptionBudgetLister, coordinator: coordinator}
+}
+
+// New creates a new eviction resource.
+func (r *EvictionREST) New() runtime.Object {
+	return &policy.Eviction{}
+}
+
+// Destroy cleans up its resources on shutdown.
+func (r *EvictionREST) Destroy() {}
+
+// Create attempts to create a new eviction, checking the pod's eligibility
+// for voluntary disruption (the break-glass annotation and the covering
+// PodDisruptionBudget's remaining budget) before deleting it.
+func (r *EvictionREST) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	eviction, ok := obj.(*policy.Eviction)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an eviction, got %T", obj))
+	}
+
+	if name != eviction.Name {
+		return nil, apierrors.NewBadRequest("name in URL does not match name in Eviction object")
+	}
+
+	if createValidation != nil {
+		if err := createValidation(ctx, eviction.DeepCopyObject()); err != nil {
+			return nil, err
+		}
+	}
+
+	reason, message, err := evictionReasonAndMessage(eviction)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	obj, err := r.store.Get(ctx, eviction.Name, &metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pod := obj.(*v1.Pod)
+
+	if preventsEviction(pod) {
+		return nil, errEvictionBlocked(pod)
+	}
+
+	if !isPodTerminal(pod) {
+		if _, err := r.checkAndDecrement(pod.Namespace, *pod); err != nil {
+			return nil, err
+		}
+	}
+
+	if feature.DefaultFeatureGate.Enabled(features.PodDisruptionConditions) {
+		// The DisruptionTarget condition is set before deletion so that
+		// controllers observing the pod (via the informer, not the delete
+		// event) can distinguish a voluntary eviction from any other delete.
+		podutilSetDisruptionCondition(pod, reason, message)
+		if _, _, err := r.store.Update(ctx, pod.Name, rest.DefaultUpdatedObjectInfo(pod), nil, nil, false, &metav1.UpdateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	deleteOptions := eviction.DeleteOptions
+	if deleteOptions == nil {
+		deleteOptions = &metav1.DeleteOptions{}
+	}
+	if _, _, err := r.store.Delete(ctx, eviction.Name, nil, deleteOptions); err != nil {
+		return nil, err
+	}
+
+	return &metav1.Status{Status: metav1.StatusSuccess}, nil
+}
+
+// preventsEviction reports whether the pod carries the break-glass
+// annotation that opts it out of PDB-governed voluntary disruption.
+func preventsEviction(pod *v1.Pod) bool {
+	return pod.Annotations[PreventEvictionAnnotation] == "true"
+}
+
+// errEvictionBlocked returns the machine-readable status Evict() should
+// surface when a pod has opted out of eviction. It does not consume any PDB
+// budget, since the PDB was never consulted.
+func errEvictionBlocked(pod *v1.Pod) *apierrors.StatusError {
+	return apierrors.NewGenericServerResponse(
+		422,
+		"create",
+		policy.Resource("pods/eviction"),
+		pod.Name,
+		fmt.Sprintf("pod %q has the %q annotation set and cannot be voluntarily evicted", pod.Name, PreventEvictionAnnotation),
+		0,
+		false,
+	)
+}
+
+func isPodTerminal(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// checkAndDecrement looks up the PDB covering pod, if any, and atomically
+// reserves one disruption against it via DisruptionController.Grant, which
+// funnels through the eviction coordinator's single serving goroutine when
+// this replica holds the coordinator lease, or falls back to the coordinator's
+// read-decrement-UpdateStatus retry loop (ReserveDisruptionsUpTo) otherwise. A
+// 429 TooManyRequests is returned when the budget is exhausted so callers can
+// retry with backoff; unlike reading DisruptionsAllowed straight off the
+// lister, this writes the decrement back so concurrent Evict calls against
+// the same budget can't all observe the same stale allowance and all pass.
+func (r *EvictionREST) checkAndDecrement(namespace string, pod v1.Pod) (*policy.PodDisruptionBudget, error) {
+	pdbs, err := r.podDisruptionBudgetLister.GetPodPodDisruptionBudgets(&pod)
+	if err != nil {
+		return nil, err
+	}
+	if len(pdbs) == 0 {
+		return nil, nil
+	}
+	pdb := pdbs[0]
+	granted, err := r.coordinator.Grant(namespace, pdb.Name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if granted < 1 {
+		return nil, apierrors.NewTooManyRequests(fmt.Sprintf(
+			"PodDisruptionBudget %s/%s does not currently allow any disruptions", namespace, pdb.Name), 0)
+	}
+	return pdb, nil
+}
+
+func podutilSetDisruptionCondition(pod *v1.Pod, reason, message string) {
+	cond := v1.PodCondition{
+		Type:    v1.DisruptionTarget,
+		Status:  v1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	if i, existing := podutil.GetPodCondition(&pod.Status, v1.DisruptionTarget); existing != nil {
+		pod.Status.Conditions[i] = cond
+		return
+	}
+	pod.Status.Conditions = append(pod.Status.Conditions, cond)
+}
+
+// evictionReasonAndMessage extracts the caller-supplied reason/message for
+// this eviction from well-known annotations on the Eviction object, falling
+// back to a generic reason when neither is set. The reason must be a C
+// identifier (letters, digits and underscores, not starting with a digit);
+// the message is bounded in length (in bytes) and must be valid UTF-8.
+func evictionReasonAndMessage(eviction *policy.Eviction) (reason, message string, err error) {
+	reason = eviction.Annotations[EvictionReasonAnnotation]
+	message = eviction.Annotations[EvictionMessageAnnotation]
+
+	if reason == "" {
+		reason = "EvictionByEvictionAPI"
+	} else if errs := validation.IsCIdentifier(reason); len(errs) > 0 {
+		return "", "", fmt.Errorf("%s: %s", EvictionReasonAnnotation, strings.Join(errs, "; "))
+	}
+
+	if message == "" {
+		message = "Evicted by the eviction API."
+	} else {
+		if !utf8.ValidString(message) {
+			return "", "", fmt.Errorf("%s: must be valid UTF-8", EvictionMessageAnnotation)
+		}
+		if len(message) > maxEvictionMessageLength {
+			return "", "", fmt.Errorf("%s: must be no more than %d bytes", EvictionMessageAnnotation, maxEvictionMessageLength)
+		}
+	}
+
+	return reason, message, nil
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/feature"
+	policyv1listers "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/kubernetes/pkg/controller/disruption"
+	"k8s.io/kubernetes/pkg/features"
+)
+
+// EvictionBatch is the request/response body for the evictions:batch
+// subresource. It groups several pod evictions that share a single
+// PodDisruptionBudget reservation, so a drain or descheduler loop can evict
+// K pods with one PDB conflict-retry instead of K independent races.
+//
+// This type lives alongside the storage that serves it rather than in
+// staging/src/k8s.io/api/policy, pending API review of the wire shape.
+type EvictionBatch struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+
+	// Evictions lists the individual pod evictions requested in this batch.
+	// All Evictions must share the same Namespace as the batch request.
+	Evictions []policy.Eviction
+
+	// PartialAllowed, if true, admits as many of Evictions as the covering
+	// PodDisruptionBudget currently allows and reports the rest as failed in
+	// Status, rather than requiring all-or-nothing admission.
+	PartialAllowed bool
+
+	// Status is populated on the response and carries one entry per
+	// requested eviction, in the same order as Evictions.
+	Status []EvictionBatchItemStatus
+}
+
+// EvictionBatchItemStatus reports the outcome for a single pod within a
+// batch eviction request.
+type EvictionBatchItemStatus struct {
+	Name    string
+	Evicted bool
+	Error   string
+}
+
+// EvictionBatchREST implements the evictions:batch subresource: it reserves
+// budget for N pods against their covering PodDisruptionBudget(s) with a
+// single status update per budget, then deletes the admitted pods.
+type EvictionBatchREST struct {
+	store                     *genericregistry.Store
+	podDisruptionBudgetLister policyv1listers.PodDisruptionBudgetLister
+	coordinator               *disruption.DisruptionController
+}
+
+var _ = rest.Creater(&EvictionBatchREST{})
+
+// newEvictionBatchStorage returns an EvictionBatchREST subresource backed by
+// the given pod store, using coordinator to reserve PDB budget.
+func newEvictionBatchStorage(store *genericregistry.Store, podDisruptionBudgetLister policyv1listers.PodDisruptionBudgetLister, coordinator *disruption.DisruptionController) *EvictionBatchREST {
+	return &EvictionBatchREST{store: store, podDisruptionBudgetLister: podDisruptionBudgetLister, coordinator: coordinator}
+}
+
+// New creates a new, empty EvictionBatch.
+func (r *EvictionBatchREST) New() runtime.Object {
+	return &EvictionBatch{}
+}
+
+// Destroy cleans up its resources on shutdown.
+func (r *EvictionBatchREST) Destroy() {}
+
+// Create evaluates every requested eviction against its covering PDB. Pods
+// that share a PDB are reserved together with a single
+// DisruptionController reservation call, so the budget is debited by
+// exactly the number of pods actually admitted for that PDB - never more
+// than DisruptionsAllowed, and in one status update rather than one per pod.
+//
+// When PartialAllowed is false (the default), any pod whose PDB lacks
+// sufficient budget causes the whole batch to be rejected: nothing is
+// reserved and nothing is deleted. When PartialAllowed is true, each PDB
+// admits as many of its group as DisruptionsAllowed currently covers -
+// min(requested, allowed) - and the rest are reported as failed in Status
+// rather than rejecting the whole group.
+func (r *EvictionBatchREST) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	batch, ok := obj.(*EvictionBatch)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected an EvictionBatch, got %T", obj))
+	}
+	if len(batch.Evictions) == 0 {
+		return nil, apierrors.NewBadRequest("evictions:batch requires at least one eviction")
+	}
+
+	if createValidation != nil {
+		if err := createValidation(ctx, batch.DeepCopyObject()); err != nil {
+			return nil, err
+		}
+	}
+
+	pods := make([]*v1.Pod, len(batch.Evictions))
+	for i, eviction := range batch.Evictions {
+		obj, err := r.store.Get(ctx, eviction.Name, &metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pods[i] = obj.(*v1.Pod)
+	}
+
+	// Pods carrying the break-glass annotation are rejected up front, the
+	// same way EvictionREST.Create rejects them before ever looking at a
+	// PDB - they must never be admitted into a budget group or deleted.
+	blocked := map[string]error{}
+	for _, pod := range pods {
+		if preventsEviction(pod) {
+			blocked[pod.Name] = errEvictionBlocked(pod)
+		}
+	}
+
+	rejected, err := r.groupByBudgetAndReserve(pods, blocked, batch.PartialAllowed)
+	if err != nil {
+		return nil, err
+	}
+	if len(rejected) > 0 && !batch.PartialAllowed {
+		return nil, apierrors.NewTooManyRequests(fmt.Sprintf(
+			"%d of %d pods in the batch are not currently evictable; rejecting the whole batch", len(rejected), len(pods)), 0)
+	}
+
+	status := make([]EvictionBatchItemStatus, len(pods))
+	for i, pod := range pods {
+		if err, isRejected := rejected[pod.Name]; isRejected {
+			status[i] = EvictionBatchItemStatus{Name: pod.Name, Evicted: false, Error: err.Error()}
+			continue
+		}
+
+		reason, message, err := evictionReasonAndMessage(&batch.Evictions[i])
+		if err != nil {
+			status[i] = EvictionBatchItemStatus{Name: pod.Name, Evicted: false, Error: err.Error()}
+			continue
+		}
+		if feature.DefaultFeatureGate.Enabled(features.PodDisruptionConditions) {
+			podutilSetDisruptionCondition(pod, reason, message)
+			if _, _, err := r.store.Update(ctx, pod.Name, rest.DefaultUpdatedObjectInfo(pod), nil, nil, false, &metav1.UpdateOptions{}); err != nil {
+				status[i] = EvictionBatchItemStatus{Name: pod.Name, Evicted: false, Error: err.Error()}
+				continue
+			}
+		}
+
+		if _, _, err := r.store.Delete(ctx, pod.Name, nil, batch.Evictions[i].DeleteOptions); err != nil {
+			status[i] = EvictionBatchItemStatus{Name: pod.Name, Evicted: false, Error: err.Error()}
+			continue
+		}
+		status[i] = EvictionBatchItemStatus{Name: pod.Name, Evicted: true}
+	}
+
+	result := batch.DeepCopy()
+	result.Status = status
+	return result, nil
+}
+
+// groupByBudgetAndReserve partitions pods by covering PodDisruptionBudget and
+// reserves budget against each one in a single call, returning the set of
+// pods whose budget could not be reserved because their PDB lacked
+// sufficient DisruptionsAllowed. Pods not covered by any PDB, and terminal
+// pods, reserve nothing and are not rejected. preRejected carries pods already rejected
+// for a reason unrelated to budget (e.g. the break-glass annotation); those
+// pods are excluded from grouping and carried straight through into the
+// returned map.
+//
+// When partialAllowed is false, each group is reserved all-or-nothing via
+// ReserveDisruptions: if the group doesn't fully fit, every pod in it is
+// rejected. When partialAllowed is true, each group is reserved via
+// DisruptionController.Grant, which admits min(len(group), DisruptionsAllowed)
+// pods from the group (in iteration order) and rejects the rest as exhausted
+// by their own batch-mates rather than failing the whole group.
+//
+// A lister error is propagated rather than treated as "no PDB covers this
+// pod" - silently admitting a pod whose coverage couldn't be determined
+// would defeat the point of asking in the first place.
+func (r *EvictionBatchREST) groupByBudgetAndReserve(pods []*v1.Pod, preRejected map[string]error, partialAllowed bool) (rejected map[string]error, err error) {
+	rejected = make(map[string]error, len(preRejected))
+	for name, rejErr := range preRejected {
+		rejected[name] = rejErr
+	}
+
+	byBudget := map[string][]*v1.Pod{}
+	for _, pod := range pods {
+		if _, isRejected := rejected[pod.Name]; isRejected {
+			continue
+		}
+		// Terminal pods never consume PDB budget; admit them unconditionally.
+		if isPodTerminal(pod) {
+			continue
+		}
+		pdbs, err := r.podDisruptionBudgetLister.GetPodPodDisruptionBudgets(pod)
+		if err != nil {
+			return nil, err
+		}
+		if len(pdbs) == 0 {
+			continue
+		}
+		pdb := pdbs[0]
+		key := pdb.Namespace + "/" + pdb.Name
+		byBudget[key] = append(byBudget[key], pod)
+	}
+
+	for key, group := range byBudget {
+		namespace, name, _ := splitNamespacedName(key)
+
+		if !partialAllowed {
+			if err := r.coordinator.ReserveDisruptions(namespace, name, int32(len(group))); err != nil {
+				for _, p := range group {
+					rejected[p.Name] = err
+				}
+			}
+			continue
+		}
+
+		granted, err := r.coordinator.Grant(namespace, name, int32(len(group)))
+		if err != nil {
+			for _, p := range group {
+				rejected[p.Name] = err
+			}
+			continue
+		}
+		for i, p := range group {
+			if int32(i) >= granted {
+				rejected[p.Name] = apierrors.NewTooManyRequests(fmt.Sprintf(
+					"PodDisruptionBudget %s/%s only allowed %d of the %d pods requested from it in this batch", namespace, name, granted, len(group)), 0)
+			}
+		}
+	}
+
+	return rejected, nil
+}
+
+func splitNamespacedName(key string) (namespace, name string, ok bool) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '/' {
+			return key[:i], key[i+1:], true
+		}
+	}
+	return "", key, false
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	policyv1listers "k8s.io/client-go/listers/policy/v1"
+)
+
+// fakePodDisruptionBudgetLister answers GetPodPodDisruptionBudgets from a
+// fixed, per-pod-name table, so groupByBudgetAndReserve can be exercised
+// without a real informer cache. List and PodDisruptionBudgets are never
+// called by the code under test and panic if that ever changes.
+type fakePodDisruptionBudgetLister struct {
+	pdbs map[string][]*policy.PodDisruptionBudget
+	errs map[string]error
+}
+
+func (f *fakePodDisruptionBudgetLister) List(selector labels.Selector) ([]*policy.PodDisruptionBudget, error) {
+	panic("not used by groupByBudgetAndReserve")
+}
+
+func (f *fakePodDisruptionBudgetLister) PodDisruptionBudgets(namespace string) policyv1listers.PodDisruptionBudgetNamespaceLister {
+	panic("not used by groupByBudgetAndReserve")
+}
+
+func (f *fakePodDisruptionBudgetLister) GetPodPodDisruptionBudgets(pod *v1.Pod) ([]*policy.PodDisruptionBudget, error) {
+	if err, ok := f.errs[pod.Name]; ok {
+		return nil, err
+	}
+	return f.pdbs[pod.Name], nil
+}
+
+func testPod(name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+}
+
+// TestGroupByBudgetAndReserveSkipsPreRejected ensures pods already rejected
+// before grouping (e.g. for carrying the break-glass prevent-eviction
+// annotation) are never looked up against a PDB and are carried straight
+// through into the returned rejected map, rather than being silently
+// re-admitted into a budget group.
+func TestGroupByBudgetAndReserveSkipsPreRejected(t *testing.T) {
+	blocked := testPod("blocked")
+	blockedErr := errors.New("blocked by annotation")
+
+	lister := &fakePodDisruptionBudgetLister{
+		errs: map[string]error{
+			// If groupByBudgetAndReserve ever looks this pod up, fail loudly
+			// instead of silently admitting it.
+			"blocked": fmt.Errorf("groupByBudgetAndReserve must not consult the PDB lister for a pre-rejected pod"),
+		},
+	}
+	r := &EvictionBatchREST{podDisruptionBudgetLister: lister}
+
+	rejected, err := r.groupByBudgetAndReserve([]*v1.Pod{blocked}, map[string]error{"blocked": blockedErr}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rejected["blocked"]; got != blockedErr {
+		t.Fatalf("expected pre-rejected pod's error to be preserved, got %v", got)
+	}
+}
+
+// TestGroupByBudgetAndReserveFailsOnListerError ensures a PDB lister failure
+// is propagated as an error from groupByBudgetAndReserve, rather than being
+// treated the same as "no PDB covers this pod" and silently admitting the
+// pod as unprotected.
+func TestGroupByBudgetAndReserveFailsOnListerError(t *testing.T) {
+	pod := testPod("some-pod")
+	listerErr := errors.New("malformed selector on another PDB")
+
+	lister := &fakePodDisruptionBudgetLister{
+		errs: map[string]error{"some-pod": listerErr},
+	}
+	r := &EvictionBatchREST{podDisruptionBudgetLister: lister}
+
+	rejected, err := r.groupByBudgetAndReserve([]*v1.Pod{pod}, map[string]error{}, false)
+	if err != listerErr {
+		t.Fatalf("expected the lister error to be propagated, got %v", err)
+	}
+	if rejected != nil {
+		t.Fatalf("expected a nil rejected map on lister failure, got %v", rejected)
+	}
+}
@@ -0,0 +1,429 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package aggregated serves the v2beta1 APIGroupDiscoveryList document built
+// from whatever groups/versions have been registered with a ResourceManager,
+// with ETag-based caching so an unchanged document never needs to be
+// re-transferred.
+package aggregated
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/vnd.kubernetes.protobuf"
+
+	// discoveryContentTypeSuffix is appended to contentTypeJSON/Protobuf to
+	// form the full negotiated Content-Type for this document, matching the
+	// Accept header clients send (see fetchPath in the tests).
+	discoveryContentTypeSuffix = ";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	codecs = runtimeserializer.NewCodecFactory(scheme)
+)
+
+func init() {
+	utilruntime.Must(apidiscoveryv2beta1.AddToScheme(scheme))
+}
+
+// ResourceManager serves an aggregated APIGroupDiscoveryList built from
+// whatever groups/versions have been registered with it.
+type ResourceManager interface {
+	// AddGroupVersion upserts a single version within group, leaving any
+	// other versions already registered for that group untouched.
+	AddGroupVersion(groupName string, value apidiscoveryv2beta1.APIVersionDiscovery)
+	// RemoveGroupVersion removes a single version from its group. A group
+	// left with no versions stops being served entirely.
+	RemoveGroupVersion(gv metav1.GroupVersion)
+	// RemoveGroup removes every version of a group.
+	RemoveGroup(groupName string)
+	// SetGroups replaces the full set of served groups.
+	SetGroups(groups []apidiscoveryv2beta1.APIGroupDiscovery)
+	// List returns the manager's current aggregated document. It exists for
+	// in-process consumers (e.g. building a client-go RESTMapper straight
+	// from the in-memory store) that would otherwise have to round-trip
+	// through their own HTTP handler and re-parse the JSON it just emitted.
+	List() apidiscoveryv2beta1.APIGroupDiscoveryList
+	// SetGroupAnnotations merges annotations into the named group's
+	// metadata, creating the group (with no versions yet) if it does not
+	// already exist. It exists so a caller merging in groups sourced from
+	// elsewhere (e.g. a federated peer aggregator) can record provenance
+	// without keeping its own parallel copy of the document.
+	SetGroupAnnotations(groupName string, annotations map[string]string)
+	// AddSource registers an external DiscoverySource under name, merging
+	// its groups into the aggregated document according to policy. A
+	// source is refreshed periodically by Run, and on-demand by any
+	// request carrying Cache-Control: no-cache. See source.go.
+	AddSource(name string, src DiscoverySource, policy SourceConflictPolicy)
+	// RemoveSource unregisters a source added with AddSource, retracting
+	// any group/versions it owns that nothing else is also serving.
+	RemoveSource(name string)
+	// Run starts the periodic refresh of every registered source, blocking
+	// until stopCh is closed. Safe to call even with no sources registered.
+	Run(stopCh <-chan struct{})
+
+	http.Handler
+}
+
+// resourceDiscoveryManager is the default ResourceManager implementation.
+// Groups are indexed by name for O(1) mutation, with apiGroupNames tracking
+// insertion order - so that two managers fed the identical sequence of
+// mutating calls serialize to byte-identical documents, and therefore agree
+// on ETag, regardless of which process computed it.
+type resourceDiscoveryManager struct {
+	lock sync.RWMutex
+
+	apiGroups     map[string]*apidiscoveryv2beta1.APIGroupDiscovery
+	apiGroupNames []string
+
+	// groupETags holds a per-group ETag, recomputed whenever that group's
+	// content changes, so a client can ask for (and the handler can serve)
+	// a partial response containing only the groups that actually changed -
+	// see partial.go.
+	groupETags map[string]string
+
+	// watchers and nextWatcherID back the ?watch=true streaming mode (see
+	// watch.go); watchers is lazily initialized since most ResourceManagers
+	// never get a single watch request.
+	watchers      map[int64]*discoveryWatcher
+	nextWatcherID int64
+
+	// sources, sourceOrder and sourceOwners back the external
+	// DiscoverySource extender mechanism (see source.go); all three are
+	// lazily initialized since most ResourceManagers never get a source
+	// registered.
+	sources      map[string]*registeredSource
+	sourceOrder  []string
+	sourceOwners map[schema.GroupVersion]string
+
+	// cborEnabled gates negotiating application/cbor; see WithCBOR in
+	// negotiate.go.
+	cborEnabled bool
+
+	// compressionLock, compressionEtag and compressionVariants back the
+	// per-revision gzip response cache in negotiate.go.
+	compressionLock     sync.Mutex
+	compressionEtag     string
+	compressionVariants map[string]*compressedVariant
+}
+
+// NewResourceManager returns a ResourceManager with no groups registered.
+func NewResourceManager(opts ...ResourceManagerOption) ResourceManager {
+	rdm := &resourceDiscoveryManager{
+		apiGroups: map[string]*apidiscoveryv2beta1.APIGroupDiscovery{},
+	}
+	for _, opt := range opts {
+		opt(rdm)
+	}
+	return rdm
+}
+
+func (rdm *resourceDiscoveryManager) AddGroupVersion(groupName string, value apidiscoveryv2beta1.APIVersionDiscovery) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	rdm.addGroupVersionLocked(groupName, value)
+}
+
+// addGroupVersionLocked is the body of AddGroupVersion, factored out so
+// other locked callers (e.g. source.go's source merge) can upsert a version
+// without re-entering rdm.lock. rdm.lock must already be held for writing.
+func (rdm *resourceDiscoveryManager) addGroupVersionLocked(groupName string, value apidiscoveryv2beta1.APIVersionDiscovery) {
+	group, exists := rdm.apiGroups[groupName]
+	if !exists {
+		group = &apidiscoveryv2beta1.APIGroupDiscovery{
+			ObjectMeta: metav1.ObjectMeta{Name: groupName},
+		}
+		rdm.apiGroups[groupName] = group
+		rdm.apiGroupNames = append(rdm.apiGroupNames, groupName)
+	}
+
+	for i := range group.Versions {
+		if group.Versions[i].Version == value.Version {
+			group.Versions[i] = value
+			rdm.updateGroupETagLocked(groupName)
+			rdm.publishLocked(discoveryWatchEvent{Type: watchEventModified, GroupName: groupName, Version: value.DeepCopy()})
+			return
+		}
+	}
+	group.Versions = append(group.Versions, value)
+	rdm.updateGroupETagLocked(groupName)
+	rdm.publishLocked(discoveryWatchEvent{Type: watchEventAdded, GroupName: groupName, Version: value.DeepCopy()})
+}
+
+func (rdm *resourceDiscoveryManager) RemoveGroupVersion(gv metav1.GroupVersion) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	rdm.removeGroupVersionLocked(gv)
+}
+
+// removeGroupVersionLocked is the body of RemoveGroupVersion, factored out
+// so other locked callers can retract a version without re-entering
+// rdm.lock. rdm.lock must already be held for writing.
+func (rdm *resourceDiscoveryManager) removeGroupVersionLocked(gv metav1.GroupVersion) {
+	group, exists := rdm.apiGroups[gv.Group]
+	if !exists {
+		return
+	}
+
+	found := false
+	for i := range group.Versions {
+		if group.Versions[i].Version == gv.Version {
+			group.Versions = append(group.Versions[:i], group.Versions[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	rdm.publishLocked(discoveryWatchEvent{Type: watchEventDeleted, GroupName: gv.Group, Version: &apidiscoveryv2beta1.APIVersionDiscovery{Version: gv.Version}})
+
+	if len(group.Versions) == 0 {
+		rdm.removeGroupLocked(gv.Group)
+	} else {
+		rdm.updateGroupETagLocked(gv.Group)
+	}
+}
+
+func (rdm *resourceDiscoveryManager) RemoveGroup(groupName string) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	if group, exists := rdm.apiGroups[groupName]; exists {
+		for _, v := range group.Versions {
+			rdm.publishLocked(discoveryWatchEvent{Type: watchEventDeleted, GroupName: groupName, Version: &apidiscoveryv2beta1.APIVersionDiscovery{Version: v.Version}})
+		}
+	}
+
+	rdm.removeGroupLocked(groupName)
+}
+
+func (rdm *resourceDiscoveryManager) removeGroupLocked(groupName string) {
+	if _, exists := rdm.apiGroups[groupName]; !exists {
+		return
+	}
+	delete(rdm.apiGroups, groupName)
+	delete(rdm.groupETags, groupName)
+	for i, name := range rdm.apiGroupNames {
+		if name == groupName {
+			rdm.apiGroupNames = append(rdm.apiGroupNames[:i], rdm.apiGroupNames[i+1:]...)
+			break
+		}
+	}
+}
+
+// updateGroupETagLocked recomputes groupName's entry in rdm.groupETags from
+// its current content. rdm.lock must already be held for writing.
+func (rdm *resourceDiscoveryManager) updateGroupETagLocked(groupName string) {
+	group, exists := rdm.apiGroups[groupName]
+	if !exists {
+		delete(rdm.groupETags, groupName)
+		return
+	}
+
+	data, err := json.Marshal(group)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	if rdm.groupETags == nil {
+		rdm.groupETags = map[string]string{}
+	}
+	rdm.groupETags[groupName] = computeETag(data)
+}
+
+func (rdm *resourceDiscoveryManager) SetGroups(groups []apidiscoveryv2beta1.APIGroupDiscovery) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	rdm.apiGroups = map[string]*apidiscoveryv2beta1.APIGroupDiscovery{}
+	rdm.apiGroupNames = nil
+	rdm.groupETags = map[string]string{}
+	for i := range groups {
+		group := groups[i].DeepCopy()
+		if _, exists := rdm.apiGroups[group.Name]; !exists {
+			rdm.apiGroupNames = append(rdm.apiGroupNames, group.Name)
+		}
+		rdm.apiGroups[group.Name] = group
+		rdm.updateGroupETagLocked(group.Name)
+	}
+
+	// SetGroups replaces the whole document at once, so there's no
+	// meaningful prior state to diff against for per-version ADDED/MODIFIED/
+	// DELETED events. Watchers instead get a fresh BOOKMARK snapshot, same
+	// as a newly-connecting watcher would, and are expected to treat it as
+	// a full resync.
+	snapshot := rdm.listLocked()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	rdm.publishLocked(discoveryWatchEvent{Type: watchEventBookmark, ResourceVersion: computeETag(data), Snapshot: snapshot})
+}
+
+// listLocked builds the full APIGroupDiscoveryList from the manager's
+// present state. rdm.lock must already be held, for reading or writing.
+func (rdm *resourceDiscoveryManager) listLocked() *apidiscoveryv2beta1.APIGroupDiscoveryList {
+	list := &apidiscoveryv2beta1.APIGroupDiscoveryList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "APIGroupDiscoveryList",
+			APIVersion: "apidiscovery.k8s.io/v2beta1",
+		},
+	}
+	for _, name := range rdm.apiGroupNames {
+		list.Items = append(list.Items, *rdm.apiGroups[name])
+	}
+	return list
+}
+
+// currentList builds the full APIGroupDiscoveryList from the manager's
+// present state.
+func (rdm *resourceDiscoveryManager) currentList() *apidiscoveryv2beta1.APIGroupDiscoveryList {
+	rdm.lock.RLock()
+	defer rdm.lock.RUnlock()
+
+	return rdm.listLocked()
+}
+
+func (rdm *resourceDiscoveryManager) List() apidiscoveryv2beta1.APIGroupDiscoveryList {
+	return *rdm.currentList()
+}
+
+func (rdm *resourceDiscoveryManager) SetGroupAnnotations(groupName string, annotations map[string]string) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	rdm.setGroupAnnotationsLocked(groupName, annotations)
+}
+
+// setGroupAnnotationsLocked is the body of SetGroupAnnotations, factored out
+// so other locked callers can record provenance without re-entering
+// rdm.lock. rdm.lock must already be held for writing.
+func (rdm *resourceDiscoveryManager) setGroupAnnotationsLocked(groupName string, annotations map[string]string) {
+	group, exists := rdm.apiGroups[groupName]
+	if !exists {
+		group = &apidiscoveryv2beta1.APIGroupDiscovery{
+			ObjectMeta: metav1.ObjectMeta{Name: groupName},
+		}
+		rdm.apiGroups[groupName] = group
+		rdm.apiGroupNames = append(rdm.apiGroupNames, groupName)
+	}
+
+	if group.Annotations == nil {
+		group.Annotations = make(map[string]string, len(annotations))
+	}
+	for k, v := range annotations {
+		group.Annotations[k] = v
+	}
+	rdm.updateGroupETagLocked(groupName)
+}
+
+func computeETag(data []byte) string {
+	sum := sha512.Sum512(data)
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sum))
+}
+
+func (rdm *resourceDiscoveryManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWatchRequest(r) {
+		rdm.serveWatch(w, r)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+		rdm.refreshSources(r.Context())
+	}
+
+	rdm.lock.RLock()
+	list := rdm.listLocked()
+	groupETags := rdm.groupETagsLocked()
+	anyStale := rdm.anyStaleSourceLocked()
+	rdm.lock.RUnlock()
+
+	if encodedETags, err := json.Marshal(groupETags); err == nil {
+		w.Header().Set(groupETagsHeader, string(encodedETags))
+	}
+	if anyStale {
+		w.Header().Set("Warning", `199 - "discovery includes stale content from an unreachable source"`)
+	}
+
+	if known, ok := parseIfNoneMatchGroups(r); ok {
+		servePartial(w, list, groupETags, known)
+		return
+	}
+
+	jsonBody, err := json.Marshal(list)
+	if err != nil {
+		utilruntime.HandleError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := computeETag(jsonBody)
+
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+
+	mediaType := rdm.negotiateMediaType(r)
+	contentType := mediaType + discoveryContentTypeSuffix
+
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := rdm.encodeBody(mediaType, list, jsonBody)
+	if err != nil {
+		utilruntime.HandleError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if mediaType == contentTypeJSON {
+		body = append(body, '\n')
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+
+	if acceptsGzip(r) {
+		if compressed, cerr := rdm.compressedBody(etag, mediaType, body); cerr == nil {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed)
+			return
+		}
+		// Fall through and serve the uncompressed body rather than fail the
+		// request over a compression-only problem.
+	}
+
+	w.Write(body)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// contentTypeCBOR is the emerging CBOR media type for the discovery
+// document. It's only negotiated when a ResourceManager was constructed
+// with WithCBOR, since CBOR support is still new enough that most clients
+// don't ask for it.
+const contentTypeCBOR = "application/cbor"
+
+// ResourceManagerOption configures optional behavior on a ResourceManager
+// constructed through NewResourceManager.
+type ResourceManagerOption func(*resourceDiscoveryManager)
+
+// WithCBOR opts a ResourceManager into negotiating application/cbor as a
+// third media type alongside JSON and protobuf. No CBOR serializer is
+// registered with this package's scheme, so a request that negotiates
+// application/cbor is answered with 500 Internal Server Error.
+func WithCBOR() ResourceManagerOption {
+	return func(rdm *resourceDiscoveryManager) {
+		rdm.cborEnabled = true
+	}
+}
+
+// negotiateMediaType picks the response media type for r's Accept header,
+// falling back to JSON if nothing more specific was asked for (or if CBOR
+// was asked for but this manager wasn't constructed with WithCBOR).
+func (rdm *resourceDiscoveryManager) negotiateMediaType(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.HasPrefix(accept, contentTypeProtobuf):
+		return contentTypeProtobuf
+	case rdm.cborEnabled && strings.HasPrefix(accept, contentTypeCBOR):
+		return contentTypeCBOR
+	default:
+		return contentTypeJSON
+	}
+}
+
+// encodeBody serializes list as mediaType, reusing the already-marshaled
+// jsonBody when mediaType is JSON rather than re-encoding it.
+func (rdm *resourceDiscoveryManager) encodeBody(mediaType string, list *apidiscoveryv2beta1.APIGroupDiscoveryList, jsonBody []byte) ([]byte, error) {
+	if mediaType == contentTypeJSON {
+		return jsonBody, nil
+	}
+
+	info, ok := runtime.SerializerInfoForMediaType(codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		return nil, fmt.Errorf("no %s serializer registered for discovery", mediaType)
+	}
+	encoder := codecs.EncoderForVersion(info.Serializer, apidiscoveryv2beta1.SchemeGroupVersion)
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(list, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptsGzip reports whether r's Accept-Encoding header lists gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.EqualFold(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]), "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// compressedVariant lazily gzips one (revision, media type) combination
+// exactly once, no matter how many concurrent requests ask for it.
+type compressedVariant struct {
+	once sync.Once
+	body []byte
+	err  error
+}
+
+// compressedBody returns the gzip-compressed form of body, computing it at
+// most once per (etag, mediaType) pair - the aggregated document is large
+// and rarely changes, so compressing it once per revision and serving the
+// cached bytes to every subsequent matching request materially cuts CPU
+// compared to gzipping on every request.
+func (rdm *resourceDiscoveryManager) compressedBody(etag, mediaType string, body []byte) ([]byte, error) {
+	rdm.compressionLock.Lock()
+	if rdm.compressionEtag != etag {
+		rdm.compressionEtag = etag
+		rdm.compressionVariants = map[string]*compressedVariant{}
+	}
+	if rdm.compressionVariants == nil {
+		rdm.compressionVariants = map[string]*compressedVariant{}
+	}
+	variant, ok := rdm.compressionVariants[mediaType]
+	if !ok {
+		variant = &compressedVariant{}
+		rdm.compressionVariants[mediaType] = variant
+	}
+	rdm.compressionLock.Unlock()
+
+	variant.once.Do(func() {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			variant.err = err
+			return
+		}
+		if err := gz.Close(); err != nil {
+			variant.err = err
+			return
+		}
+		variant.body = buf.Bytes()
+	})
+	return variant.body, variant.err
+}
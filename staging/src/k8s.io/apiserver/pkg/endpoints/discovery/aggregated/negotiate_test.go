@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+)
+
+// TestNegotiationTable exercises every (media type x encoding x etag-match)
+// combination: the compressed body always decompresses to exactly the
+// uncompressed bytes, the ETag is stable regardless of which encoding was
+// requested, and Vary names both Accept and Accept-Encoding.
+func TestNegotiationTable(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(1, 3, 30)
+	manager.SetGroups(apis.Items)
+
+	mediaTypes := []string{"application/json", "application/vnd.kubernetes.protobuf"}
+	encodings := []string{"", "gzip"}
+
+	type fetched struct {
+		etag string
+		body []byte
+	}
+	baseline := map[string]fetched{}
+
+	for _, mediaType := range mediaTypes {
+		for _, encoding := range encodings {
+			name := fmt.Sprintf("%s/encoding=%s", mediaType, encoding)
+			t.Run(name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+				req.Header.Set("Accept", mediaType+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+				if encoding != "" {
+					req.Header.Set("Accept-Encoding", encoding)
+				}
+				manager.ServeHTTP(w, req)
+
+				resp := w.Result()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "Accept, Accept-Encoding", resp.Header.Get("Vary"))
+				assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+				body := w.Body.Bytes()
+				if encoding == "gzip" {
+					require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+					gz, err := gzip.NewReader(w.Body)
+					require.NoError(t, err)
+					decompressed, err := io.ReadAll(gz)
+					require.NoError(t, err)
+					body = decompressed
+				} else {
+					assert.Empty(t, resp.Header.Get("Content-Encoding"))
+				}
+
+				baseline[mediaType] = fetched{etag: resp.Header.Get("ETag"), body: body}
+			})
+		}
+	}
+
+	// The two encodings of the same media type must decompress to identical
+	// bytes, and the ETag must not depend on which encoding was requested.
+	for _, mediaType := range mediaTypes {
+		uncompressedReq := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+		uncompressedReq.Header.Set("Accept", mediaType+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+		wUncompressed := httptest.NewRecorder()
+		manager.ServeHTTP(wUncompressed, uncompressedReq)
+
+		compressedReq := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+		compressedReq.Header.Set("Accept", mediaType+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+		compressedReq.Header.Set("Accept-Encoding", "gzip")
+		wCompressed := httptest.NewRecorder()
+		manager.ServeHTTP(wCompressed, compressedReq)
+
+		assert.Equal(t, wUncompressed.Result().Header.Get("ETag"), wCompressed.Result().Header.Get("ETag"), "ETag should not depend on Accept-Encoding")
+
+		gz, err := gzip.NewReader(wCompressed.Body)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Equal(t, wUncompressed.Body.Bytes(), decompressed, "compressed body should decompress to exactly the uncompressed bytes")
+	}
+
+	// If-None-Match still short-circuits to 304 regardless of the requested
+	// encoding.
+	for _, mediaType := range mediaTypes {
+		req := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+		req.Header.Set("Accept", mediaType+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", baseline[mediaType].etag)
+		w := httptest.NewRecorder()
+		manager.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Result().StatusCode)
+		assert.Empty(t, w.Body.Bytes())
+	}
+}
+
+// TestCBORNotNegotiatedByDefault shows that a manager constructed without
+// WithCBOR never serves application/cbor, even if asked for, falling back
+// to JSON instead.
+func TestCBORNotNegotiatedByDefault(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(1, 2, 31)
+	manager.SetGroups(apis.Items)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+	req.Header.Set("Accept", "application/cbor;g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+	manager.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "application/json;g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList", w.Result().Header.Get("Content-Type"))
+}
+
+// TestCBOREnabledNegotiatesButFailsWithoutSerializer shows that opting into
+// WithCBOR makes the manager try to honor an application/cbor Accept
+// header - this snapshot has no CBOR serializer registered with the
+// discovery scheme yet, so the request fails rather than silently falling
+// back, the same way an unregistered protobuf serializer would.
+func TestCBOREnabledNegotiatesButFailsWithoutSerializer(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager(discoveryendpoint.WithCBOR())
+	apis := fuzzAPIGroups(1, 2, 32)
+	manager.SetGroups(apis.Items)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, discoveryPath, nil)
+	req.Header.Set("Accept", "application/cbor;g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+	manager.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
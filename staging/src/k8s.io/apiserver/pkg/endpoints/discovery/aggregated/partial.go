@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// groupETagsHeader carries a JSON object of {groupName: groupETag} for
+	// every group in the response body, so a client can remember them and
+	// ask for a partial response on its next request.
+	groupETagsHeader = "X-Kubernetes-Group-ETags"
+
+	// ifNoneMatchGroupsHeader is a request header carrying a JSON object of
+	// {groupName: groupETag} the client already has cached. Groups whose
+	// ETag still matches are omitted from the response body entirely.
+	ifNoneMatchGroupsHeader = "If-None-Match-Groups"
+
+	partialContentTypeSuffix = ";partial=groups"
+)
+
+// partialDiscoveryResponse is served in place of a full APIGroupDiscoveryList
+// when the client supplies If-None-Match-Groups: only the groups whose ETag
+// has changed are included in Items: everything else the client already had
+// is named in OmittedGroups (unchanged) or RemovedGroups (no longer served).
+type partialDiscoveryResponse struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Items holds only the groups whose content differs from what the
+	// client's If-None-Match-Groups said it already had.
+	Items []apidiscoveryv2beta1.APIGroupDiscovery `json:"items"`
+	// OmittedGroups lists groups the client already had an up-to-date copy
+	// of, so weren't included in Items.
+	OmittedGroups []string `json:"omittedGroups,omitempty"`
+	// RemovedGroups lists groups the client's If-None-Match-Groups named
+	// that are no longer served at all.
+	RemovedGroups []string `json:"removedGroups,omitempty"`
+}
+
+// parseIfNoneMatchGroups parses r's If-None-Match-Groups header, if present,
+// as a {groupName: groupETag} JSON object. The second return value is false
+// if the header is absent or malformed, in which case the caller should fall
+// back to serving the full document.
+func parseIfNoneMatchGroups(r *http.Request) (map[string]string, bool) {
+	header := r.Header.Get(ifNoneMatchGroupsHeader)
+	if header == "" {
+		return nil, false
+	}
+	var known map[string]string
+	if err := json.Unmarshal([]byte(header), &known); err != nil {
+		return nil, false
+	}
+	return known, true
+}
+
+// groupETagsLocked returns a copy of rdm.groupETags. rdm.lock must already
+// be held, for reading or writing.
+func (rdm *resourceDiscoveryManager) groupETagsLocked() map[string]string {
+	etags := make(map[string]string, len(rdm.groupETags))
+	for k, v := range rdm.groupETags {
+		etags[k] = v
+	}
+	return etags
+}
+
+// servePartial writes a partialDiscoveryResponse built by comparing list's
+// groups against the ETags the client claimed to already have. known is
+// assumed already parsed from the If-None-Match-Groups request header.
+func servePartial(w http.ResponseWriter, list *apidiscoveryv2beta1.APIGroupDiscoveryList, groupETags map[string]string, known map[string]string) {
+	resp := partialDiscoveryResponse{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "APIGroupDiscoveryList",
+			APIVersion: "apidiscovery.k8s.io/v2beta1",
+		},
+	}
+
+	seen := make(map[string]bool, len(list.Items))
+	for _, group := range list.Items {
+		seen[group.Name] = true
+		if knownETag, ok := known[group.Name]; ok && knownETag == groupETags[group.Name] {
+			resp.OmittedGroups = append(resp.OmittedGroups, group.Name)
+			continue
+		}
+		resp.Items = append(resp.Items, group)
+	}
+	for name := range known {
+		if !seen[name] {
+			resp.RemovedGroups = append(resp.RemovedGroups, name)
+		}
+	}
+	sort.Strings(resp.OmittedGroups)
+	sort.Strings(resp.RemovedGroups)
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON+partialContentTypeSuffix+discoveryContentTypeSuffix)
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(body)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+)
+
+// partialResponse mirrors the unexported partialDiscoveryResponse shape this
+// package serves when a request carries If-None-Match-Groups.
+type partialResponse struct {
+	Items         []apidiscoveryv2beta1.APIGroupDiscovery `json:"items"`
+	OmittedGroups []string                                `json:"omittedGroups,omitempty"`
+	RemovedGroups []string                                `json:"removedGroups,omitempty"`
+}
+
+func fetchWithKnownGroupETags(handler http.Handler, known map[string]string) (*http.Response, map[string]string, *partialResponse) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", discoveryPath, nil)
+	req.Header.Set("Accept", "application/json;g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+	if known != nil {
+		encoded, _ := json.Marshal(known)
+		req.Header.Set("If-None-Match-Groups", string(encoded))
+	}
+
+	handler.ServeHTTP(w, req)
+
+	response := w.Result()
+	var groupETags map[string]string
+	json.Unmarshal([]byte(response.Header.Get("X-Kubernetes-Group-ETags")), &groupETags)
+
+	var partial *partialResponse
+	if body := w.Body.Bytes(); len(body) > 0 {
+		partial = &partialResponse{}
+		json.Unmarshal(body, partial)
+	}
+
+	return response, groupETags, partial
+}
+
+// TestGroupETagsHeader shows every response, partial or not, carries a
+// X-Kubernetes-Group-ETags header naming the current ETag of every served
+// group.
+func TestGroupETagsHeader(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(2, 4, 20)
+	manager.SetGroups(apis.Items)
+
+	_, groupETags, _ := fetchWithKnownGroupETags(manager, nil)
+	require.Len(t, groupETags, len(apis.Items))
+	for _, group := range apis.Items {
+		assert.NotEmpty(t, groupETags[group.Name], "expected an ETag for group %q", group.Name)
+	}
+}
+
+// TestPartialResponseSingleGroupChanged shows that when a client's
+// If-None-Match-Groups names every group it knows about and only one
+// group's content has actually changed, the partial response's Items
+// contains only that one changed group, with the rest named in
+// OmittedGroups.
+func TestPartialResponseSingleGroupChanged(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(3, 3, 21)
+	manager.SetGroups(apis.Items)
+
+	_, initialETags, _ := fetchWithKnownGroupETags(manager, nil)
+	require.Len(t, initialETags, 3)
+
+	changedGroup := apis.Items[0].Name
+	newVersion := apidiscoveryv2beta1.APIVersionDiscovery{Version: "v-changed"}
+	manager.AddGroupVersion(changedGroup, newVersion)
+
+	response, _, partial := fetchWithKnownGroupETags(manager, initialETags)
+	require.Equal(t, http.StatusPartialContent, response.StatusCode)
+	require.NotNil(t, partial)
+
+	require.Len(t, partial.Items, 1, "only the changed group should be in Items")
+	assert.Equal(t, changedGroup, partial.Items[0].Name)
+	assert.Empty(t, partial.RemovedGroups)
+	assert.Len(t, partial.OmittedGroups, 2, "the two unchanged groups should be omitted")
+	assert.NotContains(t, partial.OmittedGroups, changedGroup)
+}
+
+// TestPartialResponseRemovedGroup shows a group the client knew about that
+// has since stopped being served shows up in RemovedGroups.
+func TestPartialResponseRemovedGroup(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(2, 2, 22)
+	manager.SetGroups(apis.Items)
+
+	_, initialETags, _ := fetchWithKnownGroupETags(manager, nil)
+	require.Len(t, initialETags, 2)
+
+	removedGroup := apis.Items[0].Name
+	for _, version := range apis.Items[0].Versions {
+		manager.RemoveGroupVersion(metav1.GroupVersion{Group: removedGroup, Version: version.Version})
+	}
+
+	response, _, partial := fetchWithKnownGroupETags(manager, initialETags)
+	require.Equal(t, http.StatusPartialContent, response.StatusCode)
+	require.NotNil(t, partial)
+
+	assert.Contains(t, partial.RemovedGroups, removedGroup)
+	assert.Empty(t, partial.Items)
+	assert.Len(t, partial.OmittedGroups, 1)
+}
+
+// TestPartialResponseNoKnownGroups shows that an empty If-None-Match-Groups
+// (the client knows nothing yet) still triggers partial mode, with every
+// group landing in Items and nothing omitted or removed.
+func TestPartialResponseNoKnownGroups(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	apis := fuzzAPIGroups(1, 3, 23)
+	manager.SetGroups(apis.Items)
+
+	response, _, partial := fetchWithKnownGroupETags(manager, map[string]string{})
+	require.Equal(t, http.StatusPartialContent, response.StatusCode)
+	require.NotNil(t, partial)
+
+	assert.Len(t, partial.Items, len(apis.Items))
+	assert.Empty(t, partial.OmittedGroups)
+	assert.Empty(t, partial.RemovedGroups)
+}
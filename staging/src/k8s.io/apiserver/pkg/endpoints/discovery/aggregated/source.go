@@ -0,0 +1,332 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// DiscoverySource is a pluggable external supplier of discovery content -
+// e.g. another cluster's own aggregated discovery document, fetched over
+// HTTP with no aggregation-layer APIService involved. Implementations must
+// be safe for concurrent use.
+type DiscoverySource interface {
+	// Fetch returns the source's current groups plus an opaque revision
+	// token identifying that exact content. previousRevision is the token
+	// the manager last saw from this source (empty until a fetch has
+	// succeeded; a Cache-Control: no-cache refresh passes it too); a source that recognizes it as still
+	// current may return unchanged=true and skip re-encoding its groups.
+	Fetch(ctx context.Context, previousRevision string) (groups []apidiscoveryv2beta1.APIGroupDiscovery, revision string, unchanged bool, err error)
+}
+
+// SourceConflictPolicy says how a registered source's group/version should
+// be handled when it collides with a group/version already being served
+// from somewhere else - a locally-registered group, or a different source.
+type SourceConflictPolicy string
+
+const (
+	// SourcePreferLocal keeps whatever is already being served locally
+	// (i.e. registered through AddGroupVersion/SetGroups rather than a
+	// source), ignoring the source's conflicting version.
+	SourcePreferLocal SourceConflictPolicy = "PreferLocal"
+	// SourcePreferSource lets this source's version overwrite whatever is
+	// already being served, local or not.
+	SourcePreferSource SourceConflictPolicy = "PreferSource"
+	// SourceRejectConflict drops the source's conflicting version
+	// entirely, leaving whatever was already being served untouched.
+	SourceRejectConflict SourceConflictPolicy = "Reject"
+)
+
+// sourceOriginAnnotation records, on a merged group's metadata, the name of
+// the source a group's versions were most recently merged in from. It's
+// best-effort provenance, not an authoritative per-version record - a group
+// whose versions come from more than one source will only show the most
+// recently merged one.
+const sourceOriginAnnotation = "discovery.k8s.io/source"
+
+// localSourceOwner marks a group/version in resourceDiscoveryManager.sourceOwners
+// as coming from a locally-registered group rather than an external source.
+const localSourceOwner = ""
+
+// sourceFetchTimeout bounds how long a single source's Fetch may take
+// before it's treated as a failure and served stale.
+const sourceFetchTimeout = 5 * time.Second
+
+// registeredSource is the last known-good fetch from one external
+// DiscoverySource, kept around so a temporarily-unreachable source can keep
+// serving its last known content, flagged stale, rather than disappearing
+// from the aggregated document.
+type registeredSource struct {
+	source DiscoverySource
+	policy SourceConflictPolicy
+
+	lastRevision string
+	lastGroups   []apidiscoveryv2beta1.APIGroupDiscovery
+	stale        bool
+
+	// ownedGroupVersions is every group/version this source's content is
+	// currently published under, so removal and re-merges know what to
+	// retract.
+	ownedGroupVersions []schema.GroupVersion
+}
+
+func (rdm *resourceDiscoveryManager) AddSource(name string, src DiscoverySource, policy SourceConflictPolicy) {
+	rdm.lock.Lock()
+	if rdm.sources == nil {
+		rdm.sources = map[string]*registeredSource{}
+	}
+	if _, exists := rdm.sources[name]; !exists {
+		rdm.sourceOrder = append(rdm.sourceOrder, name)
+	}
+	rdm.sources[name] = &registeredSource{source: src, policy: policy}
+	rdm.lock.Unlock()
+
+	rdm.refreshSource(context.Background(), name)
+}
+
+func (rdm *resourceDiscoveryManager) RemoveSource(name string) {
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+
+	source, exists := rdm.sources[name]
+	if !exists {
+		return
+	}
+	delete(rdm.sources, name)
+	for i, n := range rdm.sourceOrder {
+		if n == name {
+			rdm.sourceOrder = append(rdm.sourceOrder[:i], rdm.sourceOrder[i+1:]...)
+			break
+		}
+	}
+
+	for _, gv := range source.ownedGroupVersions {
+		if rdm.sourceOwners[gv] != name {
+			continue
+		}
+		delete(rdm.sourceOwners, gv)
+		rdm.removeGroupVersionLocked(metav1.GroupVersion{Group: gv.Group, Version: gv.Version})
+	}
+}
+
+func (rdm *resourceDiscoveryManager) Run(stopCh <-chan struct{}) {
+	rdm.refreshSources(context.Background())
+	wait.Until(func() { rdm.refreshSources(context.Background()) }, time.Second, stopCh)
+}
+
+// anyStaleSourceLocked reports whether at least one registered source is
+// currently being served from stale (last known-good, but failed-to-refresh)
+// content. rdm.lock must already be held, for reading or writing.
+func (rdm *resourceDiscoveryManager) anyStaleSourceLocked() bool {
+	for _, source := range rdm.sources {
+		if source.stale {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshSources fetches every registered source in turn and merges its
+// content into the document.
+func (rdm *resourceDiscoveryManager) refreshSources(ctx context.Context) {
+	rdm.lock.RLock()
+	names := make([]string, len(rdm.sourceOrder))
+	copy(names, rdm.sourceOrder)
+	rdm.lock.RUnlock()
+
+	for _, name := range names {
+		rdm.refreshSource(ctx, name)
+	}
+}
+
+func (rdm *resourceDiscoveryManager) refreshSource(ctx context.Context, name string) {
+	rdm.lock.RLock()
+	source, ok := rdm.sources[name]
+	lastRevision := ""
+	if ok {
+		lastRevision = source.lastRevision
+	}
+	rdm.lock.RUnlock()
+	if !ok {
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, sourceFetchTimeout)
+	groups, revision, unchanged, err := source.source.Fetch(fetchCtx, lastRevision)
+	cancel()
+
+	rdm.lock.Lock()
+	defer rdm.lock.Unlock()
+	source, ok = rdm.sources[name]
+	if !ok {
+		// Removed while the fetch was in flight.
+		return
+	}
+
+	if err != nil {
+		klog.V(2).InfoS("failed to fetch discovery source, serving last known content", "source", name, "err", err)
+		source.stale = true
+		rdm.republishSourceStaleLocked(name, source)
+		return
+	}
+
+	source.stale = false
+	if unchanged {
+		// Source is healthy and nothing changed; no need to re-merge.
+		return
+	}
+
+	source.lastRevision = revision
+	source.lastGroups = groups
+	rdm.mergeSourceLocked(name, source, groups)
+}
+
+// mergeSourceLocked merges groups into the document, applying source's
+// conflict policy against whatever already owns a given group/version, and
+// records the new set of group/versions source owns. rdm.lock must be held.
+func (rdm *resourceDiscoveryManager) mergeSourceLocked(name string, source *registeredSource, groups []apidiscoveryv2beta1.APIGroupDiscovery) {
+	if rdm.sourceOwners == nil {
+		rdm.sourceOwners = map[schema.GroupVersion]string{}
+	}
+
+	var owned []schema.GroupVersion
+	for _, group := range groups {
+		for _, version := range group.Versions {
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+
+			owner, conflict := rdm.sourceOwners[gv]
+			if !conflict {
+				// Locally-registered versions are not recorded in
+				// sourceOwners; detect them from the served document.
+				if existing, ok := rdm.apiGroups[gv.Group]; ok {
+					for _, v := range existing.Versions {
+						if v.Version == gv.Version {
+							owner, conflict = localSourceOwner, true
+							break
+						}
+					}
+				}
+			}
+			if conflict && owner != name {
+				switch source.policy {
+				case SourcePreferLocal:
+					if owner == localSourceOwner {
+						klog.V(2).InfoS("dropping source group/version in favor of local registration", "source", name, "group", gv.Group, "version", gv.Version)
+						continue
+					}
+				case SourceRejectConflict:
+					klog.V(2).InfoS("rejecting source group/version already owned by another source", "source", name, "owner", owner, "group", gv.Group, "version", gv.Version)
+					continue
+				case SourcePreferSource:
+					// fall through and overwrite owner below
+				}
+			}
+
+			rdm.sourceOwners[gv] = name
+			rdm.addGroupVersionLocked(group.Name, version)
+			rdm.setGroupAnnotationsLocked(group.Name, map[string]string{sourceOriginAnnotation: name})
+			owned = append(owned, gv)
+		}
+	}
+
+	stillOwned := make(map[schema.GroupVersion]bool, len(owned))
+	for _, gv := range owned {
+		stillOwned[gv] = true
+	}
+	for _, gv := range source.ownedGroupVersions {
+		if stillOwned[gv] {
+			continue
+		}
+		if rdm.sourceOwners[gv] == name {
+			delete(rdm.sourceOwners, gv)
+			rdm.removeGroupVersionLocked(metav1.GroupVersion{Group: gv.Group, Version: gv.Version})
+		}
+	}
+	source.ownedGroupVersions = owned
+}
+
+// republishSourceStaleLocked re-publishes source's last known group/versions
+// flagged as stale, without re-running conflict resolution - source already
+// owns them from its last successful merge. rdm.lock must be held.
+func (rdm *resourceDiscoveryManager) republishSourceStaleLocked(name string, source *registeredSource) {
+	for _, group := range source.lastGroups {
+		for _, version := range group.Versions {
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+			if rdm.sourceOwners[gv] != name {
+				continue
+			}
+			stale := version.DeepCopy()
+			stale.Freshness = apidiscoveryv2beta1.DiscoveryFreshnessStale
+			rdm.addGroupVersionLocked(group.Name, *stale)
+		}
+	}
+}
+
+// HTTPDiscoverySource is a DiscoverySource that GETs a remote cluster's own
+// aggregated discovery document over HTTP, enabling federation of discovery
+// across clusters with no aggregation-layer APIService involved.
+type HTTPDiscoverySource struct {
+	// Client is used to issue the GET. Required.
+	Client *http.Client
+	// URL is the full URL of the remote aggregated discovery endpoint,
+	// e.g. "https://remote.example.com/apis". Required.
+	URL string
+}
+
+// Fetch implements DiscoverySource by GETing h.URL, sending
+// previousRevision back as an If-None-Match so an unchanged remote document
+// produces a cheap 304 response.
+func (h *HTTPDiscoverySource) Fetch(ctx context.Context, previousRevision string) (groups []apidiscoveryv2beta1.APIGroupDiscovery, revision string, unchanged bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	req.Header.Set("Accept", contentTypeJSON+discoveryContentTypeSuffix)
+	if previousRevision != "" {
+		req.Header.Set("If-None-Match", previousRevision)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("fetching discovery from %s: %w", h.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, previousRevision, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("fetching discovery from %s: unexpected status %d", h.URL, resp.StatusCode)
+	}
+
+	var doc apidiscoveryv2beta1.APIGroupDiscoveryList
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, "", false, fmt.Errorf("decoding discovery document from %s: %w", h.URL, err)
+	}
+
+	return doc.Items, resp.Header.Get("ETag"), false, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+)
+
+// fakeSource is a DiscoverySource a test can script: it serves a fixed set
+// of groups under a given revision, optionally failing or stalling first.
+type fakeSource struct {
+	mu sync.Mutex
+
+	groups   []apidiscoveryv2beta1.APIGroupDiscovery
+	revision string
+	fail     bool
+	delay    time.Duration
+
+	fetchCount int32
+}
+
+func (f *fakeSource) Fetch(ctx context.Context, previousRevision string) ([]apidiscoveryv2beta1.APIGroupDiscovery, string, bool, error) {
+	atomic.AddInt32(&f.fetchCount, 1)
+
+	f.mu.Lock()
+	delay, fail, groups, revision := f.delay, f.fail, f.groups, f.revision
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, "", false, ctx.Err()
+		}
+	}
+	if fail {
+		return nil, "", false, fmt.Errorf("simulated source failure")
+	}
+	if previousRevision != "" && previousRevision == revision {
+		return nil, revision, true, nil
+	}
+	return groups, revision, false, nil
+}
+
+func (f *fakeSource) setGroups(revision string, groups []apidiscoveryv2beta1.APIGroupDiscovery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revision = revision
+	f.groups = groups
+	f.fail = false
+}
+
+func (f *fakeSource) setFailing(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func oneGroupDiscovery(group, version string) apidiscoveryv2beta1.APIGroupDiscovery {
+	return apidiscoveryv2beta1.APIGroupDiscovery{
+		ObjectMeta: metav1.ObjectMeta{Name: group},
+		Versions: []apidiscoveryv2beta1.APIVersionDiscovery{
+			{Version: version},
+		},
+	}
+}
+
+// TestSourceMerge shows a registered source's groups show up in the
+// aggregated document, with provenance recorded on the group.
+func TestSourceMerge(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	source := &fakeSource{}
+	source.setGroups("rev-1", []apidiscoveryv2beta1.APIGroupDiscovery{oneGroupDiscovery("remote.example.com", "v1")})
+
+	manager.AddSource("remote", source, discoveryendpoint.SourcePreferSource)
+
+	list := manager.List()
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "remote.example.com", list.Items[0].Name)
+	assert.Equal(t, "remote", list.Items[0].Annotations["discovery.k8s.io/source"])
+}
+
+// TestSourceFailureServesStale shows that when a source starts failing, its
+// last known-good content keeps being served (flagged stale) rather than
+// disappearing, and the response carries a warning header.
+func TestSourceFailureServesStale(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	source := &fakeSource{}
+	source.setGroups("rev-1", []apidiscoveryv2beta1.APIGroupDiscovery{oneGroupDiscovery("remote.example.com", "v1")})
+	manager.AddSource("remote", source, discoveryendpoint.SourcePreferSource)
+
+	require.Len(t, manager.List().Items, 1)
+
+	source.setFailing(true)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	manager.ServeHTTP(w, req)
+
+	require.Len(t, manager.List().Items, 1, "stale content should keep being served")
+	assert.Equal(t, apidiscoveryv2beta1.DiscoveryFreshnessStale, manager.List().Items[0].Versions[0].Freshness)
+	assert.NotEmpty(t, w.Result().Header.Get("Warning"), "expected a warning header while a source is stale")
+}
+
+// TestSourceTimeout shows a source that never responds is treated as a
+// failure once the fetch's internal deadline elapses, rather than blocking
+// the refresh forever.
+func TestSourceTimeout(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+	source := &fakeSource{delay: 24 * time.Hour}
+	source.setGroups("rev-1", []apidiscoveryv2beta1.APIGroupDiscovery{oneGroupDiscovery("slow.example.com", "v1")})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.AddSource("slow", source, discoveryendpoint.SourcePreferSource)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("AddSource's initial refresh did not return - source fetch timeout was not enforced")
+	}
+
+	// The slow source never successfully completed a fetch, so it should
+	// not have contributed any groups.
+	assert.Empty(t, manager.List().Items)
+}
+
+// TestSourceConcurrentUpdates shows many sources refreshing concurrently
+// don't race or drop each other's groups.
+func TestSourceConcurrentUpdates(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+
+	numSources := 20
+	sources := make([]*fakeSource, numSources)
+	for i := 0; i < numSources; i++ {
+		s := &fakeSource{}
+		s.setGroups("rev-1", []apidiscoveryv2beta1.APIGroupDiscovery{
+			oneGroupDiscovery(fmt.Sprintf("group%d.example.com", i), "v1"),
+		})
+		sources[i] = s
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s *fakeSource) {
+			defer wg.Done()
+			manager.AddSource(fmt.Sprintf("source%d", i), s, discoveryendpoint.SourcePreferSource)
+		}(i, s)
+	}
+	wg.Wait()
+
+	require.Len(t, manager.List().Items, numSources)
+
+	stopCh := make(chan struct{})
+	go manager.Run(stopCh)
+	time.Sleep(50 * time.Millisecond)
+	close(stopCh)
+
+	assert.Len(t, manager.List().Items, numSources, "concurrent periodic refreshes should not drop any source's groups")
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregated_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+)
+
+// watchTestEvent mirrors the unexported discoveryWatchEvent shape this
+// package's watch mode emits, so the test can decode the NDJSON stream.
+type watchTestEvent struct {
+	Type            string                                      `json:"type"`
+	GroupName       string                                      `json:"groupName,omitempty"`
+	Version         *apidiscoveryv2beta1.APIVersionDiscovery     `json:"version,omitempty"`
+	ResourceVersion string                                      `json:"resourceVersion,omitempty"`
+	Snapshot        *apidiscoveryv2beta1.APIGroupDiscoveryList  `json:"snapshot,omitempty"`
+}
+
+// syncResponseWriter is a minimal http.ResponseWriter + http.Flusher that's
+// safe to read from a different goroutine than the one writing to it, since
+// the watch handler streams chunks from its own goroutine while the test
+// reads the accumulated body from the main goroutine.
+type syncResponseWriter struct {
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+	code   int
+}
+
+func newSyncResponseWriter() *syncResponseWriter {
+	return &syncResponseWriter{header: http.Header{}}
+}
+
+func (s *syncResponseWriter) Header() http.Header { return s.header }
+
+func (s *syncResponseWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.Write(p)
+}
+
+func (s *syncResponseWriter) WriteHeader(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code = code
+}
+
+func (s *syncResponseWriter) Flush() {}
+
+func (s *syncResponseWriter) events(t *testing.T) []watchTestEvent {
+	t.Helper()
+	s.mu.Lock()
+	raw := s.body.String()
+	s.mu.Unlock()
+
+	var events []watchTestEvent
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	for {
+		var ev watchTestEvent
+		if err := dec.Decode(&ev); err != nil {
+			break
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// TestWatch shows that a ?watch=true connection receives an initial BOOKMARK
+// snapshot followed by ADDED/MODIFIED/DELETED events as the manager changes.
+func TestWatch(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, discoveryPath+"?watch=true", nil).WithContext(ctx)
+	w := newSyncResponseWriter()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.ServeHTTP(w, req)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(w.events(t)) >= 1
+	}, 2*time.Second, 10*time.Millisecond, "expected an initial BOOKMARK event")
+
+	initial := w.events(t)
+	require.Equal(t, "BOOKMARK", initial[0].Type)
+	require.NotNil(t, initial[0].Snapshot)
+	require.Empty(t, initial[0].Snapshot.Items)
+	require.NotEmpty(t, initial[0].ResourceVersion)
+
+	manager.AddGroupVersion("foo.example.com", apidiscoveryv2beta1.APIVersionDiscovery{Version: "v1"})
+
+	require.Eventually(t, func() bool {
+		return len(w.events(t)) >= 2
+	}, 2*time.Second, 10*time.Millisecond, "expected an ADDED event")
+	added := w.events(t)[1]
+	require.Equal(t, "ADDED", added.Type)
+	require.Equal(t, "foo.example.com", added.GroupName)
+	require.Equal(t, "v1", added.Version.Version)
+
+	manager.RemoveGroupVersion(metav1.GroupVersion{Group: "foo.example.com", Version: "v1"})
+
+	require.Eventually(t, func() bool {
+		return len(w.events(t)) >= 3
+	}, 2*time.Second, 10*time.Millisecond, "expected a DELETED event")
+	removed := w.events(t)[2]
+	require.Equal(t, "DELETED", removed.Type)
+	require.Equal(t, "foo.example.com", removed.GroupName)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ServeHTTP did not return after request context was canceled")
+	}
+}
+
+// TestWatchConcurrent opens many concurrent watchers against a manager under
+// concurrent writes, then cancels every watcher's context and verifies every
+// ServeHTTP call returns and the goroutine count settles back down - i.e.
+// that watching doesn't leak a goroutine or a registered watcher per
+// connection.
+func TestWatchConcurrent(t *testing.T) {
+	manager := discoveryendpoint.NewResourceManager()
+
+	numWatchers := 50
+	numWrites := 200
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var watcherGroup sync.WaitGroup
+	for i := 0; i < numWatchers; i++ {
+		req := httptest.NewRequest(http.MethodGet, discoveryPath+"?watch=true", nil).WithContext(ctx)
+		w := newSyncResponseWriter()
+
+		watcherGroup.Add(1)
+		go func() {
+			defer watcherGroup.Done()
+			manager.ServeHTTP(w, req)
+		}()
+	}
+
+	var writerGroup sync.WaitGroup
+	writerGroup.Add(1)
+	go func() {
+		defer writerGroup.Done()
+		for i := 0; i < numWrites; i++ {
+			manager.AddGroupVersion("foo.example.com", apidiscoveryv2beta1.APIVersionDiscovery{Version: "v1"})
+			manager.RemoveGroupVersion(metav1.GroupVersion{Group: "foo.example.com", Version: "v1"})
+		}
+	}()
+	writerGroup.Wait()
+
+	cancel()
+
+	watcherDone := make(chan struct{})
+	go func() {
+		watcherGroup.Wait()
+		close(watcherDone)
+	}()
+
+	select {
+	case <-watcherDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not all watchers returned after their context was canceled")
+	}
+
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before+2
+	}, 2*time.Second, 10*time.Millisecond, "goroutine count should settle back down once all watchers disconnect")
+}
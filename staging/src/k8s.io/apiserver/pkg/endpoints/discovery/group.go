@@ -0,0 +1,57 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package discovery contains the pre-aggregated-discovery (legacy) handlers
+// that serve a single APIGroup or a single GroupVersion's APIResourceList.
+// These predate the v2 APIGroupDiscoveryList protocol served under
+// discovery/aggregated and remain in place for clients, and extension
+// apiservers, that only speak the old protocol.
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// APIGroupHandler serves the fixed metav1.APIGroup it was constructed with,
+// e.g. mounted at /apis/extensions.
+type APIGroupHandler struct {
+	serializer serializer.CodecFactory
+	group      metav1.APIGroup
+}
+
+// NewAPIGroupHandler returns a handler that always serves the fixed group
+// passed in, rather than computing the version list per-request.
+func NewAPIGroupHandler(codecs serializer.CodecFactory, group metav1.APIGroup) *APIGroupHandler {
+	if len(group.Kind) == 0 {
+		group.Kind = "APIGroup"
+	}
+	return &APIGroupHandler{
+		serializer: codecs,
+		group:      group,
+	}
+}
+
+func (s *APIGroupHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(resp).Encode(s.group); err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+	}
+}
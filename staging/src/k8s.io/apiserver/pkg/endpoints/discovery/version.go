@@ -0,0 +1,75 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// APIResourceLister knows how to list the resources served for a given
+// GroupVersion at the time of the request, e.g. to reflect resources that
+// were dynamically registered after the server started.
+type APIResourceLister interface {
+	ListAPIResources() []metav1.APIResource
+}
+
+// APIResourceListerFunc adapts a plain function to an APIResourceLister.
+type APIResourceListerFunc func() []metav1.APIResource
+
+func (f APIResourceListerFunc) ListAPIResources() []metav1.APIResource {
+	return f()
+}
+
+// APIVersionHandler serves the metav1.APIResourceList for a single
+// GroupVersion, e.g. mounted at /apis/extensions/v1beta1.
+type APIVersionHandler struct {
+	serializer        serializer.CodecFactory
+	groupVersion      schema.GroupVersion
+	apiResourceLister APIResourceLister
+}
+
+// NewAPIVersionHandler returns a handler serving the resources reported by
+// lister for groupVersion.
+func NewAPIVersionHandler(codecs serializer.CodecFactory, groupVersion schema.GroupVersion, lister APIResourceLister) *APIVersionHandler {
+	return &APIVersionHandler{
+		serializer:        codecs,
+		groupVersion:      groupVersion,
+		apiResourceLister: lister,
+	}
+}
+
+func (s *APIVersionHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	list := metav1.APIResourceList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "APIResourceList",
+			APIVersion: "v1",
+		},
+		GroupVersion: s.groupVersion.String(),
+		APIResources: s.apiResourceLister.ListAPIResources(),
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(resp).Encode(list); err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+	}
+}
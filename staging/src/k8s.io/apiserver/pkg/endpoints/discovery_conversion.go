@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"strings"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConvertGroupVersionIntoToDiscovery converts the legacy, pre-aggregated
+// []metav1.APIResource (as served under /apis/{group}/{version}) into the
+// v2beta1 discovery format, folding subresources (e.g. "pods/status") into
+// their parent resource's Subresources list so callers that only speak the
+// old protocol can still be represented in an aggregated
+// APIGroupDiscoveryList.
+func ConvertGroupVersionIntoToDiscovery(resources []metav1.APIResource) ([]apidiscoveryv2beta1.APIResourceDiscovery, error) {
+	var converted []apidiscoveryv2beta1.APIResourceDiscovery
+	indexOfResource := map[string]int{}
+
+	for _, r := range resources {
+		if parent, sub, isSubresource := strings.Cut(r.Name, "/"); isSubresource {
+			i, ok := indexOfResource[parent]
+			if !ok {
+				// Subresource appeared before its parent in the list; keep a
+				// placeholder so nothing is dropped and fill it in if/when
+				// the parent resource is seen.
+				converted = append(converted, apidiscoveryv2beta1.APIResourceDiscovery{Resource: parent})
+				i = len(converted) - 1
+				indexOfResource[parent] = i
+			}
+			converted[i].Subresources = append(converted[i].Subresources, apidiscoveryv2beta1.APISubresourceDiscovery{
+				Subresource: sub,
+				Verbs:       r.Verbs,
+			})
+			continue
+		}
+
+		scope := apidiscoveryv2beta1.ScopeCluster
+		if r.Namespaced {
+			scope = apidiscoveryv2beta1.ScopeNamespace
+		}
+
+		resource := apidiscoveryv2beta1.APIResourceDiscovery{
+			Resource:         r.Name,
+			SingularResource: r.SingularName,
+			Scope:            scope,
+			Verbs:            r.Verbs,
+			ShortNames:       r.ShortNames,
+			Categories:       r.Categories,
+		}
+
+		if i, ok := indexOfResource[r.Name]; ok {
+			// Placeholder created by an earlier subresource; preserve what
+			// was already recorded and fill in the rest.
+			resource.Subresources = converted[i].Subresources
+			converted[i] = resource
+		} else {
+			converted = append(converted, resource)
+			indexOfResource[r.Name] = len(converted) - 1
+		}
+	}
+
+	return converted, nil
+}
+
+// ConvertGroupVersionDiscoveryToLegacy is the counterpart of
+// ConvertGroupVersionIntoToDiscovery: it turns v2beta1 resources back into
+// the legacy []metav1.APIResource representation served under
+// /api(s)/{group}/{version}, re-flattening each subresource into its own
+// "parent/sub" entry. Kind is taken from ResponseKind, which
+// ConvertGroupVersionIntoToDiscovery does not set, so a round trip through
+// both leaves Kind empty.
+func ConvertGroupVersionDiscoveryToLegacy(resources []apidiscoveryv2beta1.APIResourceDiscovery) []metav1.APIResource {
+	var out []metav1.APIResource
+	for _, r := range resources {
+		namespaced := r.Scope == apidiscoveryv2beta1.ScopeNamespace
+
+		var kind string
+		if r.ResponseKind != nil {
+			kind = r.ResponseKind.Kind
+		}
+
+		out = append(out, metav1.APIResource{
+			Name:         r.Resource,
+			SingularName: r.SingularResource,
+			Namespaced:   namespaced,
+			Kind:         kind,
+			Verbs:        r.Verbs,
+			ShortNames:   r.ShortNames,
+			Categories:   r.Categories,
+		})
+
+		for _, sub := range r.Subresources {
+			out = append(out, metav1.APIResource{
+				Name:       r.Resource + "/" + sub.Subresource,
+				Namespaced: namespaced,
+				Kind:       kind,
+				Verbs:      sub.Verbs,
+			})
+		}
+	}
+	return out
+}
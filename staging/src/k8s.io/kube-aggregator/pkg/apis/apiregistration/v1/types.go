@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1 holds the APIService type through which the kube-aggregator
+// learns about additional API groups served by extension apiservers.
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// APIService represents a server for a particular GroupVersion.
+// Name must be "version.group".
+type APIService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   APIServiceSpec   `json:"spec,omitempty"`
+	Status APIServiceStatus `json:"status,omitempty"`
+}
+
+// ServiceReference holds a reference to a Service in an arbitrary namespace.
+// An empty Service reference indicates that the group/version is served
+// directly by the aggregator itself rather than by an extension apiserver.
+type ServiceReference struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Port      *int32 `json:"port,omitempty"`
+}
+
+// APIServiceSpec contains information for locating and communicating with a
+// server that implements a particular GroupVersion.
+type APIServiceSpec struct {
+	Service  *ServiceReference `json:"service,omitempty"`
+	Group    string            `json:"group,omitempty"`
+	Version  string            `json:"version,omitempty"`
+
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify,omitempty"`
+	CABundle              []byte `json:"caBundle,omitempty"`
+
+	GroupPriorityMinimum int32 `json:"groupPriorityMinimum"`
+	VersionPriority      int32 `json:"versionPriority"`
+}
+
+// ConditionStatus indicates the status of a condition (true, false, or unknown).
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// APIServiceConditionType is a valid value for APIServiceCondition.Type.
+type APIServiceConditionType string
+
+const (
+	// Available indicates that the service exists and is reachable.
+	Available APIServiceConditionType = "Available"
+)
+
+// APIServiceCondition describes the state of an APIService at a particular point.
+type APIServiceCondition struct {
+	Type               APIServiceConditionType `json:"type"`
+	Status             ConditionStatus         `json:"status"`
+	LastTransitionTime metav1.Time             `json:"lastTransitionTime,omitempty"`
+	Reason             string                  `json:"reason,omitempty"`
+	Message            string                  `json:"message,omitempty"`
+}
+
+// APIServiceStatus contains derived information about an API server.
+type APIServiceStatus struct {
+	Conditions []APIServiceCondition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// APIServiceList is a list of APIService objects.
+type APIServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []APIService `json:"items"`
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/endpoints"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+	"k8s.io/klog/v2"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+// DiscoveryManager aggregates the discovery document served by every
+// registered APIService's backing handler into a single ResourceManager,
+// fetching every handler once when Run starts and afterwards re-polling only
+// handlers whose APIService is marked dirty.
+type DiscoveryManager interface {
+	// AddAPIService registers handler as the backend for apiService. handler
+	// is expected to answer discovery requests the same way a real extension
+	// apiserver would - either the v2beta1 aggregated protocol at /apis, or,
+	// failing that, the legacy per-group/per-version protocol.
+	AddAPIService(apiService *apiregistrationv1.APIService, handler http.Handler)
+	// RemoveAPIService stops aggregating the named APIService and removes
+	// its group/version from the served document.
+	RemoveAPIService(apiServiceName string)
+	// Run performs an initial synchronous fetch from every registered
+	// APIService, then refreshes dirty services on an interval until stopCh
+	// is closed.
+	Run(stopCh <-chan struct{})
+	// ExternalServicesSynced reports whether the initial fetch performed by
+	// Run has completed. It is an informer-style cache.InformerSynced so it
+	// can be passed directly to cache.WaitForCacheSync.
+	ExternalServicesSynced() bool
+	// OpenAPIV2Handler serves the OpenAPI v2 document merged from every
+	// registered APIService's /openapi/v2 endpoint.
+	OpenAPIV2Handler() http.Handler
+	// RESTMapper returns a client-go RESTMapper built directly from the
+	// in-memory aggregated discovery document. Each call returns a snapshot
+	// of the document at that moment; a returned mapper does not change when
+	// APIServices are later added, removed, or refreshed.
+	RESTMapper() meta.RESTMapper
+	// AddPeerAggregator registers handler as a whole other aggregator's
+	// discovery endpoint to federate in, merging its full document into
+	// this one on a periodic refresh. Conflicting group/versions (already
+	// served locally, or by a different peer) are resolved by policy.
+	AddPeerAggregator(name string, handler http.Handler, policy PeerConflictPolicy)
+	// RemovePeerAggregator stops federating the named peer and retracts
+	// whatever group/versions it was the resolved owner of.
+	RemovePeerAggregator(name string)
+	// LegacyRootAPIHandler serves the pre-aggregated-discovery protocol
+	// (/api and /api/{version}) for the core group, built from whichever
+	// registered APIService is currently serving it.
+	LegacyRootAPIHandler() http.Handler
+}
+
+type apiServiceInfo struct {
+	apiService *apiregistrationv1.APIService
+	handler    http.Handler
+}
+
+type discoveryManager struct {
+	lock sync.RWMutex
+
+	target  discoveryendpoint.ResourceManager
+	openapi *openAPIManager
+
+	apiServices          map[string]apiServiceInfo
+	dirtyAPIServiceNames map[string]struct{}
+
+	servicesSynced bool
+}
+
+// NewDiscoveryManager returns a DiscoveryManager that publishes its merged
+// document through target.
+func NewDiscoveryManager(target discoveryendpoint.ResourceManager) DiscoveryManager {
+	return &discoveryManager{
+		target:               target,
+		openapi:              newOpenAPIManager(),
+		apiServices:          map[string]apiServiceInfo{},
+		dirtyAPIServiceNames: map[string]struct{}{},
+	}
+}
+
+func (dm *discoveryManager) AddAPIService(apiService *apiregistrationv1.APIService, handler http.Handler) {
+	dm.openapi.addAPIService(apiService, handler)
+
+	if apiService.Spec.Service == nil {
+		// A nil Service reference means this group/version is served
+		// directly by the aggregator's own apiserver, which already
+		// populates the shared ResourceManager itself - nothing to proxy.
+		return
+	}
+
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	dm.apiServices[apiService.Name] = apiServiceInfo{apiService: apiService, handler: handler}
+	dm.dirtyAPIServiceNames[apiService.Name] = struct{}{}
+}
+
+func (dm *discoveryManager) RemoveAPIService(apiServiceName string) {
+	dm.openapi.removeAPIService(apiServiceName)
+
+	dm.lock.Lock()
+	info, ok := dm.apiServices[apiServiceName]
+	if ok {
+		delete(dm.apiServices, apiServiceName)
+		delete(dm.dirtyAPIServiceNames, apiServiceName)
+	}
+	dm.lock.Unlock()
+
+	if ok {
+		dm.target.RemoveGroupVersion(metav1.GroupVersion{Group: info.apiService.Spec.Group, Version: info.apiService.Spec.Version})
+	}
+}
+
+func (dm *discoveryManager) OpenAPIV2Handler() http.Handler {
+	return dm.openapi
+}
+
+// RESTMapper builds a client-go RESTMapper directly from dm.target's current
+// in-memory document - no HTTP round trip through dm's own handler needed,
+// since target.List gives back exactly what that handler would have served.
+// The result is a fresh snapshot each call, so callers that hold onto it
+// across a discovery refresh should call RESTMapper again to pick up changes.
+//
+// Resources from a version flagged DiscoveryFreshnessStale are omitted: a
+// stale entry reflects the last known-good state of an unreachable
+// APIService, and mapping to it risks directing a client at a resource that
+// may have since been renamed or removed. ShortNames and Categories are not
+// carried over, since meta.RESTMapper has no concept of either.
+func (dm *discoveryManager) RESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+
+	for _, group := range dm.target.List().Items {
+		for _, version := range group.Versions {
+			if version.Freshness == apidiscoveryv2beta1.DiscoveryFreshnessStale {
+				continue
+			}
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+
+			for _, resource := range version.Resources {
+				if resource.ResponseKind == nil {
+					continue
+				}
+
+				scope := meta.RESTScopeRoot
+				if resource.Scope == apidiscoveryv2beta1.ScopeNamespace {
+					scope = meta.RESTScopeNamespace
+				}
+
+				gvk := gv.WithKind(resource.ResponseKind.Kind)
+				plural := gv.WithResource(resource.Resource)
+				singular := gv.WithResource(resource.SingularResource)
+				mapper.AddSpecific(gvk, plural, singular, scope)
+			}
+		}
+	}
+
+	return mapper
+}
+
+func (dm *discoveryManager) Run(stopCh <-chan struct{}) {
+	dm.syncAll()
+	dm.openapi.refresh()
+
+	dm.lock.Lock()
+	dm.servicesSynced = true
+	dm.lock.Unlock()
+
+	go wait.Until(dm.openapi.refresh, time.Second, stopCh)
+	// Federated peer aggregators are registered with dm.target as
+	// DiscoverySources (see peer_discovery.go); dm.target.Run drives their
+	// periodic refresh the same way it would for any other source.
+	go dm.target.Run(stopCh)
+	wait.Until(dm.syncDirty, time.Second, stopCh)
+}
+
+func (dm *discoveryManager) ExternalServicesSynced() bool {
+	dm.lock.RLock()
+	defer dm.lock.RUnlock()
+	return dm.servicesSynced
+}
+
+func (dm *discoveryManager) syncAll() {
+	dm.lock.RLock()
+	names := make([]string, 0, len(dm.apiServices))
+	for name := range dm.apiServices {
+		names = append(names, name)
+	}
+	dm.lock.RUnlock()
+
+	for _, name := range names {
+		dm.syncAPIService(name)
+	}
+}
+
+func (dm *discoveryManager) syncDirty() {
+	dm.lock.RLock()
+	names := make([]string, 0, len(dm.dirtyAPIServiceNames))
+	for name := range dm.dirtyAPIServiceNames {
+		names = append(names, name)
+	}
+	dm.lock.RUnlock()
+
+	for _, name := range names {
+		dm.syncAPIService(name)
+	}
+}
+
+// syncAPIService fetches discovery for name's backing handler and, if name
+// is still registered once the fetch completes, publishes the result.
+// Re-checking registration after the (possibly slow) fetch - rather than
+// only before it - keeps a concurrent RemoveAPIService from racing a stale
+// result back in.
+func (dm *discoveryManager) syncAPIService(name string) {
+	dm.lock.RLock()
+	info, ok := dm.apiServices[name]
+	dm.lock.RUnlock()
+	if !ok {
+		return
+	}
+
+	fetched, err := fetchAPIServiceDiscovery(info)
+	if err != nil {
+		klog.V(2).InfoS("failed to fetch discovery for APIService", "name", name, "err", err)
+		return
+	}
+
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	if _, stillRegistered := dm.apiServices[name]; !stillRegistered {
+		return
+	}
+	delete(dm.dirtyAPIServiceNames, name)
+	dm.target.AddGroupVersion(fetched.group, fetched.version)
+}
+
+type fetchedGroupVersion struct {
+	group   string
+	version apidiscoveryv2beta1.APIVersionDiscovery
+}
+
+// fetchAPIServiceDiscovery queries info's handler for its discovery
+// document, preferring the v2beta1 aggregated protocol and falling back to
+// the legacy per-group/per-version protocol for handlers that only speak
+// that one. An APIService for the core group never speaks the aggregated
+// protocol under /apis, so it's always probed at /api/{version} instead.
+func fetchAPIServiceDiscovery(info apiServiceInfo) (*fetchedGroupVersion, error) {
+	if info.apiService.Spec.Group == "" {
+		return fetchLegacyCoreDiscovery(info)
+	}
+	if fetched, err := fetchAggregatedDiscovery(info); err == nil {
+		return fetched, nil
+	}
+	return fetchLegacyDiscovery(info)
+}
+
+func fetchAggregatedDiscovery(info apiServiceInfo) (*fetchedGroupVersion, error) {
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+	req.Header.Set("Accept", runtime.ContentTypeJSON+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+	w := httptest.NewRecorder()
+	info.handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("aggregated discovery fetch for %s returned status %d", info.apiService.Name, w.Code)
+	}
+
+	var doc apidiscoveryv2beta1.APIGroupDiscoveryList
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		return nil, fmt.Errorf("decoding aggregated discovery from %s: %w", info.apiService.Name, err)
+	}
+
+	for _, group := range doc.Items {
+		if group.Name != info.apiService.Spec.Group {
+			continue
+		}
+		for _, version := range group.Versions {
+			if version.Version == info.apiService.Spec.Version {
+				return &fetchedGroupVersion{group: group.Name, version: version}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("APIService %s: group/version %s/%s not present in its own aggregated discovery document", info.apiService.Name, info.apiService.Spec.Group, info.apiService.Spec.Version)
+}
+
+// fetchLegacyDiscovery reconstructs a v2beta1 APIVersionDiscovery from the
+// pre-aggregated-discovery APIResourceList served at
+// /apis/{group}/{version}.
+func fetchLegacyDiscovery(info apiServiceInfo) (*fetchedGroupVersion, error) {
+	group := info.apiService.Spec.Group
+	version := info.apiService.Spec.Version
+
+	path := fmt.Sprintf("/apis/%s/%s", group, version)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	info.handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("legacy discovery fetch of %s returned status %d", path, w.Code)
+	}
+
+	var resourceList metav1.APIResourceList
+	if err := json.Unmarshal(w.Body.Bytes(), &resourceList); err != nil {
+		return nil, fmt.Errorf("decoding legacy APIResourceList from %s: %w", path, err)
+	}
+
+	converted, err := endpoints.ConvertGroupVersionIntoToDiscovery(resourceList.APIResources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fetchedGroupVersion{
+		group: group,
+		version: apidiscoveryv2beta1.APIVersionDiscovery{
+			Version:   version,
+			Resources: converted,
+			Freshness: apidiscoveryv2beta1.DiscoveryFreshnessCurrent,
+		},
+	}, nil
+}
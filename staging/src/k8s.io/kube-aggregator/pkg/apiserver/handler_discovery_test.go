@@ -27,6 +27,7 @@ import (
 	fuzz "github.com/google/gofuzz"
 	"github.com/stretchr/testify/require"
 	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -203,6 +204,66 @@ func TestRemoveAPIService(t *testing.T) {
 	}
 }
 
+// Show that RESTMapper reflects a registered APIService's resources, and
+// that those mappings disappear once the APIService is removed.
+func TestRESTMapper(t *testing.T) {
+	service := discoveryendpoint.NewResourceManager()
+	service.SetGroups([]apidiscoveryv2beta1.APIGroupDiscovery{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "stable.example.com"},
+			Versions: []apidiscoveryv2beta1.APIVersionDiscovery{
+				{
+					Version: "v1",
+					Resources: []apidiscoveryv2beta1.APIResourceDiscovery{
+						{
+							Resource:         "widgets",
+							SingularResource: "widget",
+							ResponseKind:     &metav1.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "Widget"},
+							Scope:            apidiscoveryv2beta1.ScopeNamespace,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	aggyService := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggyService)
+
+	apiService := &apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1.stable.example.com"},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group:   "stable.example.com",
+			Version: "v1",
+			Service: &apiregistrationv1.ServiceReference{Name: "test-service"},
+		},
+	}
+	aggregatedManager.AddAPIService(apiService, service)
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+	cache.WaitForCacheSync(testCtx.Done(), aggregatedManager.ExternalServicesSynced)
+
+	widgetGVK := schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "Widget"}
+	mapping, err := aggregatedManager.RESTMapper().RESTMapping(widgetGVK.GroupKind(), widgetGVK.Version)
+	if err != nil {
+		t.Fatalf("expected a mapping for %s, got error: %v", widgetGVK, err)
+	}
+	if mapping.Resource.Resource != "widgets" {
+		t.Errorf("expected resource %q, got %q", "widgets", mapping.Resource.Resource)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		t.Errorf("expected namespaced scope, got %q", mapping.Scope.Name())
+	}
+
+	aggregatedManager.RemoveAPIService(apiService.Name)
+
+	if _, err := aggregatedManager.RESTMapper().RESTMapping(widgetGVK.GroupKind(), widgetGVK.Version); err == nil {
+		t.Errorf("expected no mapping for %s after RemoveAPIService, got one", widgetGVK)
+	}
+}
+
 func TestLegacyFallback(t *testing.T) {
 	aggregatedResourceManager := discoveryendpoint.NewResourceManager()
 
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints"
+)
+
+// fetchLegacyCoreDiscovery fetches discovery for an APIService representing
+// the core ("") group. Such a service is only ever probed with the
+// pre-aggregated-discovery protocol, at /api/{version} rather than
+// /apis/{group}/{version} - matching the path client-go's DiscoveryClient
+// uses against a server that doesn't support the v2beta1 protocol for the
+// core group. The /api version list is not fetched.
+func fetchLegacyCoreDiscovery(info apiServiceInfo) (*fetchedGroupVersion, error) {
+	version := info.apiService.Spec.Version
+
+	path := fmt.Sprintf("/api/%s", version)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	info.handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("legacy core discovery fetch of %s returned status %d", path, w.Code)
+	}
+
+	var resourceList metav1.APIResourceList
+	if err := json.Unmarshal(w.Body.Bytes(), &resourceList); err != nil {
+		return nil, fmt.Errorf("decoding legacy APIResourceList from %s: %w", path, err)
+	}
+
+	converted, err := endpoints.ConvertGroupVersionIntoToDiscovery(resourceList.APIResources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fetchedGroupVersion{
+		group: "",
+		version: apidiscoveryv2beta1.APIVersionDiscovery{
+			Version:   version,
+			Resources: converted,
+			Freshness: apidiscoveryv2beta1.DiscoveryFreshnessCurrent,
+		},
+	}, nil
+}
+
+// LegacyRootAPIHandler serves the pre-aggregated-discovery protocol for the
+// core group (GET /api and /api/{version}) straight from the aggregated
+// document's "" group, so legacy clients that only speak that protocol keep
+// working even when core-group resources are backed by an APIService rather
+// than served by the kube-apiserver itself.
+func (dm *discoveryManager) LegacyRootAPIHandler() http.Handler {
+	return http.HandlerFunc(dm.serveLegacyRootAPI)
+}
+
+func (dm *discoveryManager) serveLegacyRootAPI(w http.ResponseWriter, r *http.Request) {
+	var coreGroup *apidiscoveryv2beta1.APIGroupDiscovery
+	for _, group := range dm.target.List().Items {
+		if group.Name == "" {
+			g := group
+			coreGroup = &g
+			break
+		}
+	}
+	if coreGroup == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Path == "/api" || r.URL.Path == "/api/" {
+		versions := make([]string, 0, len(coreGroup.Versions))
+		for _, v := range coreGroup.Versions {
+			versions = append(versions, v.Version)
+		}
+		writeLegacyJSON(w, metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions", APIVersion: "v1"},
+			Versions: versions,
+		})
+		return
+	}
+
+	version := strings.TrimPrefix(r.URL.Path, "/api/")
+	for _, v := range coreGroup.Versions {
+		if v.Version != version {
+			continue
+		}
+		writeLegacyJSON(w, metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: schema.GroupVersion{Version: version}.String(),
+			APIResources: endpoints.ConvertGroupVersionDiscoveryToLegacy(v.Resources),
+		})
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func writeLegacyJSON(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(obj)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+	"k8s.io/client-go/tools/cache"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"k8s.io/kube-aggregator/pkg/apiserver"
+)
+
+// TestLegacyCoreGroupFallback shows that an APIService registered for the
+// core group is probed at /api/v1 rather than /apis/{group}/{version},
+// that its resources show up under the "" group in the aggregated v2
+// document, and that the aggregator's own /api endpoint mirrors them for
+// clients that only speak the pre-aggregated-discovery protocol.
+func TestLegacyCoreGroupFallback(t *testing.T) {
+	aggregatedResourceManager := discoveryendpoint.NewResourceManager()
+
+	resource := metav1.APIResource{
+		Name:         "pods",
+		SingularName: "pod",
+		Namespaced:   true,
+		Kind:         "Pod",
+		Verbs:        []string{"get", "list", "watch"},
+	}
+
+	coreHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			json.NewEncoder(w).Encode(metav1.APIVersions{Versions: []string{"v1"}})
+		case "/api/v1":
+			json.NewEncoder(w).Encode(metav1.APIResourceList{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{resource},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	aggregatedManager := apiserver.NewDiscoveryManager(aggregatedResourceManager)
+	aggregatedManager.AddAPIService(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1."},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group:   "",
+			Version: "v1",
+			Service: &apiregistrationv1.ServiceReference{Name: "legacy-core-service"},
+		},
+	}, coreHandler)
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+	require.True(t, cache.WaitForCacheSync(testCtx.Done(), aggregatedManager.ExternalServicesSynced))
+
+	// The v2 aggregated document carries the core group's resources under
+	// the empty group name.
+	list := aggregatedResourceManager.List()
+	var coreGroup *apidiscoveryv2beta1.APIGroupDiscovery
+	for i := range list.Items {
+		if list.Items[i].Name == "" {
+			coreGroup = &list.Items[i]
+		}
+	}
+	require.NotNil(t, coreGroup, "expected a \"\" group in the aggregated document")
+	require.Len(t, coreGroup.Versions, 1)
+	require.Equal(t, "v1", coreGroup.Versions[0].Version)
+	require.Len(t, coreGroup.Versions[0].Resources, 1)
+	require.Equal(t, "pods", coreGroup.Versions[0].Resources[0].Resource)
+
+	// The aggregator's own /api/v1 mirrors it back out in the legacy shape.
+	w := httptest.NewRecorder()
+	aggregatedManager.LegacyRootAPIHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resourceList metav1.APIResourceList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resourceList))
+	require.Len(t, resourceList.APIResources, 1)
+	require.Equal(t, "pods", resourceList.APIResources[0].Name)
+	require.True(t, resourceList.APIResources[0].Namespaced)
+
+	w = httptest.NewRecorder()
+	aggregatedManager.LegacyRootAPIHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var apiVersions metav1.APIVersions
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiVersions))
+	require.Equal(t, []string{"v1"}, apiVersions.Versions)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"k8s.io/klog/v2"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+// openAPIDocument is a trimmed, schema-agnostic view of an OpenAPI v2
+// (swagger) document - just enough structure to merge "definitions" blocks
+// from multiple sources without needing the full validation/spec model.
+type openAPIDocument struct {
+	Swagger     string                 `json:"swagger,omitempty"`
+	Definitions map[string]interface{} `json:"definitions,omitempty"`
+}
+
+// openAPIServiceEntry is the last known-good fetch from one APIService's
+// handler, kept around so a temporarily-unreachable backend can still
+// contribute stale content (flagged via a Warning header) instead of
+// disappearing from the merged document.
+type openAPIServiceEntry struct {
+	apiService *apiregistrationv1.APIService
+	handler    http.Handler
+
+	lastGood map[string]interface{} // definition name -> raw schema
+	stale    bool
+}
+
+// openAPIManager aggregates the OpenAPI v2 documents served by every
+// registered APIService's handler into one merged document, parallel to how
+// discoveryManager aggregates the APIGroupDiscoveryList.
+//
+// OpenAPI v3's per-group/version documents use a materially different shape
+// (component schemas rather than flat "definitions", and a per-GVK path
+// rather than one combined document) - merging that well enough to be
+// trustworthy is left as a follow-up; this manager covers v2 only.
+type openAPIManager struct {
+	lock sync.RWMutex
+
+	entries map[string]*openAPIServiceEntry
+	order   []string // insertion order; later entries win definition-name collisions
+}
+
+func newOpenAPIManager() *openAPIManager {
+	return &openAPIManager{
+		entries: map[string]*openAPIServiceEntry{},
+	}
+}
+
+func (om *openAPIManager) addAPIService(apiService *apiregistrationv1.APIService, handler http.Handler) {
+	if apiService.Spec.Service == nil {
+		return
+	}
+
+	om.lock.Lock()
+	defer om.lock.Unlock()
+
+	if _, exists := om.entries[apiService.Name]; !exists {
+		om.order = append(om.order, apiService.Name)
+	}
+	om.entries[apiService.Name] = &openAPIServiceEntry{apiService: apiService, handler: handler}
+}
+
+func (om *openAPIManager) removeAPIService(name string) {
+	om.lock.Lock()
+	defer om.lock.Unlock()
+
+	if _, exists := om.entries[name]; !exists {
+		return
+	}
+	delete(om.entries, name)
+	for i, n := range om.order {
+		if n == name {
+			om.order = append(om.order[:i], om.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// refresh re-fetches /openapi/v2 from every registered APIService's handler.
+// A service whose fetch fails keeps serving its lastGood content, marked
+// stale, rather than being dropped from the merged document.
+func (om *openAPIManager) refresh() {
+	om.lock.RLock()
+	names := make([]string, len(om.order))
+	copy(names, om.order)
+	om.lock.RUnlock()
+
+	for _, name := range names {
+		om.lock.RLock()
+		entry, ok := om.entries[name]
+		om.lock.RUnlock()
+		if !ok {
+			continue
+		}
+
+		doc, err := fetchOpenAPIV2(entry.apiService, entry.handler)
+
+		om.lock.Lock()
+		if _, stillRegistered := om.entries[name]; stillRegistered {
+			if err != nil {
+				klog.V(2).InfoS("failed to fetch OpenAPI v2 for APIService, serving stale content", "name", name, "err", err)
+				entry.stale = true
+			} else {
+				entry.lastGood = doc.Definitions
+				entry.stale = false
+			}
+		}
+		om.lock.Unlock()
+	}
+}
+
+func fetchOpenAPIV2(apiService *apiregistrationv1.APIService, handler http.Handler) (*openAPIDocument, error) {
+	req := httptest.NewRequest(http.MethodGet, "/openapi/v2", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("OpenAPI v2 fetch for APIService %s returned status %d", apiService.Name, w.Code)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		return nil, fmt.Errorf("decoding OpenAPI v2 document from APIService %s: %w", apiService.Name, err)
+	}
+
+	tagged := make(map[string]interface{}, len(doc.Definitions))
+	for name, schema := range doc.Definitions {
+		tagged[name] = taggedWithSourceGVK(schema, apiService)
+	}
+	doc.Definitions = tagged
+	return &doc, nil
+}
+
+// taggedWithSourceGVK stamps a definition's schema with the
+// x-kubernetes-group-version-kind extension of the APIService it came from,
+// so a consumer of the merged document can tell which backend a given type
+// originated from.
+func taggedWithSourceGVK(schema interface{}, apiService *apiregistrationv1.APIService) interface{} {
+	asMap, ok := schema.(map[string]interface{})
+	if !ok {
+		return schema
+	}
+	out := make(map[string]interface{}, len(asMap)+1)
+	for k, v := range asMap {
+		out[k] = v
+	}
+	out["x-kubernetes-group-version-kind"] = []map[string]string{{
+		"group":   apiService.Spec.Group,
+		"version": apiService.Spec.Version,
+	}}
+	return out
+}
+
+// merged builds the combined document and a stable ETag over it. Definition
+// name collisions are resolved in APIService registration order, so the
+// most-recently-added APIService's definition wins - matching how a newer
+// APIService is expected to supersede an older one serving the same type.
+func (om *openAPIManager) merged() (body []byte, etag string, anyStale bool) {
+	om.lock.RLock()
+	defer om.lock.RUnlock()
+
+	merged := map[string]interface{}{}
+	for _, name := range om.order {
+		entry := om.entries[name]
+		if entry.stale {
+			anyStale = true
+		}
+		for defName, schema := range entry.lastGood {
+			merged[defName] = schema
+		}
+	}
+
+	doc := openAPIDocument{Swagger: "2.0", Definitions: merged}
+	data, err := json.Marshal(canonicalizeForHash(doc))
+	if err != nil {
+		klog.ErrorS(err, "failed to marshal merged OpenAPI document")
+		return nil, "", anyStale
+	}
+
+	sum := sha512.Sum512(data)
+	return data, fmt.Sprintf("%q", fmt.Sprintf("%x", sum)), anyStale
+}
+
+// canonicalizeForHash returns doc as a plain map for marshaling. Its
+// definitions are still a Go map; json.Marshal writes map keys in sorted
+// order, which is what makes the output - and therefore the resulting ETag -
+// independent of Go map iteration order.
+func canonicalizeForHash(doc openAPIDocument) map[string]interface{} {
+	names := make([]string, 0, len(doc.Definitions))
+	for name := range doc.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := make(map[string]interface{}, len(doc.Definitions))
+	for _, name := range names {
+		ordered[name] = doc.Definitions[name]
+	}
+	return map[string]interface{}{
+		"swagger":     doc.Swagger,
+		"definitions": ordered,
+	}
+}
+
+func (om *openAPIManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, etag, stale := om.merged()
+
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if stale {
+		w.Header().Set("Warning", `199 - "aggregated OpenAPI document includes stale data from an unreachable APIService"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	w.Write(body)
+}
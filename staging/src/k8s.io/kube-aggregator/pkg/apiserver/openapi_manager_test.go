@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"k8s.io/kube-aggregator/pkg/apiserver"
+)
+
+func swaggerHandler(t *testing.T, definitions map[string]interface{}) http.HandlerFunc {
+	t.Helper()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openapi/v2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"swagger":     "2.0",
+			"definitions": definitions,
+		}))
+	}
+}
+
+// TestOpenAPIMerge shows that the aggregated /openapi/v2 document combines
+// definitions from every registered APIService and stamps each with the
+// x-kubernetes-group-version-kind of the service it came from.
+func TestOpenAPIMerge(t *testing.T) {
+	aggregatedResourceManager := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggregatedResourceManager)
+
+	aggregatedManager.AddAPIService(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1.foo.example.com"},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group: "foo.example.com", Version: "v1",
+			Service: &apiregistrationv1.ServiceReference{Name: "foo-service"},
+		},
+	}, swaggerHandler(t, map[string]interface{}{
+		"com.example.Foo": map[string]interface{}{"type": "object"},
+	}))
+
+	aggregatedManager.AddAPIService(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1.bar.example.com"},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group: "bar.example.com", Version: "v1",
+			Service: &apiregistrationv1.ServiceReference{Name: "bar-service"},
+		},
+	}, swaggerHandler(t, map[string]interface{}{
+		"com.example.Bar": map[string]interface{}{"type": "object"},
+	}))
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+
+	require.Eventually(t, func() bool {
+		resp := httptest.NewRecorder()
+		aggregatedManager.OpenAPIV2Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/openapi/v2", nil))
+		var doc map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &doc); err != nil {
+			return false
+		}
+		defs, _ := doc["definitions"].(map[string]interface{})
+		return len(defs) == 2
+	}, 2*time.Second, 10*time.Millisecond, "expected both services' definitions to be merged")
+
+	resp := httptest.NewRecorder()
+	aggregatedManager.OpenAPIV2Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/openapi/v2", nil))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &doc))
+	defs := doc["definitions"].(map[string]interface{})
+
+	foo := defs["com.example.Foo"].(map[string]interface{})
+	fooGVKs := foo["x-kubernetes-group-version-kind"].([]interface{})
+	require.Len(t, fooGVKs, 1)
+	require.Equal(t, "foo.example.com", fooGVKs[0].(map[string]interface{})["group"])
+
+	bar := defs["com.example.Bar"].(map[string]interface{})
+	barGVKs := bar["x-kubernetes-group-version-kind"].([]interface{})
+	require.Len(t, barGVKs, 1)
+	require.Equal(t, "bar.example.com", barGVKs[0].(map[string]interface{})["group"])
+}
+
+// TestOpenAPIMergeETagShortCircuit shows that an unchanged merged document
+// is reported via a 304 when the caller already has its ETag.
+func TestOpenAPIMergeETagShortCircuit(t *testing.T) {
+	aggregatedResourceManager := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggregatedResourceManager)
+
+	aggregatedManager.AddAPIService(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: "v1.foo.example.com"},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group: "foo.example.com", Version: "v1",
+			Service: &apiregistrationv1.ServiceReference{Name: "foo-service"},
+		},
+	}, swaggerHandler(t, map[string]interface{}{
+		"com.example.Foo": map[string]interface{}{"type": "object"},
+	}))
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+
+	var etag string
+	require.Eventually(t, func() bool {
+		resp := httptest.NewRecorder()
+		aggregatedManager.OpenAPIV2Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/openapi/v2", nil))
+		etag = resp.Header().Get("ETag")
+		return etag != ""
+	}, 2*time.Second, 10*time.Millisecond, "expected an ETag once the initial fetch completes")
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi/v2", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp := httptest.NewRecorder()
+	aggregatedManager.OpenAPIV2Handler().ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusNotModified, resp.Code)
+	require.Empty(t, resp.Body.Bytes())
+}
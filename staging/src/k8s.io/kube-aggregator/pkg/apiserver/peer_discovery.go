@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+)
+
+// PeerConflictPolicy says how a federated peer's group/version should be
+// handled when it collides with a group/version already being served from
+// somewhere else (a locally-registered APIService, or a different peer).
+//
+// It is an alias of discoveryendpoint.SourceConflictPolicy: a federated peer
+// is registered with dm.target as an ordinary DiscoverySource (see
+// peerDiscoverySource below), so conflict resolution between peers - and the
+// policy values naming it - are exactly the generic source mechanism's.
+type PeerConflictPolicy = discoveryendpoint.SourceConflictPolicy
+
+const (
+	// PreferLocal keeps whatever is already being served by a
+	// locally-registered APIService, ignoring the peer's conflicting
+	// version.
+	PreferLocal = discoveryendpoint.SourcePreferLocal
+	// PreferPeer lets this peer's version overwrite whatever is already
+	// being served, local or not.
+	PreferPeer = discoveryendpoint.SourcePreferSource
+	// RejectConflict drops the peer's conflicting version entirely,
+	// leaving whatever was already being served untouched.
+	RejectConflict = discoveryendpoint.SourceRejectConflict
+)
+
+func (dm *discoveryManager) AddPeerAggregator(name string, handler http.Handler, policy PeerConflictPolicy) {
+	dm.target.AddSource(name, &peerDiscoverySource{handler: handler}, policy)
+}
+
+func (dm *discoveryManager) RemovePeerAggregator(name string) {
+	dm.target.RemoveSource(name)
+}
+
+// peerDiscoverySource adapts a federated peer aggregator's in-process
+// http.Handler to discoveryendpoint.DiscoverySource, so dm.target can merge,
+// conflict-resolve and stale-republish it exactly as it would any other
+// external source - there's no peer-specific merge logic left in this
+// package. It's the in-process analogue of discoveryendpoint.HTTPDiscoverySource
+// for a peer reachable only through an http.Handler rather than a real URL.
+type peerDiscoverySource struct {
+	handler http.Handler
+}
+
+// Fetch implements discoveryendpoint.DiscoverySource by invoking the peer's
+// handler with the same v2beta1 content negotiation real clients use,
+// sending previousRevision back as an If-None-Match so an unchanged peer
+// produces a cheap 304 response.
+func (p *peerDiscoverySource) Fetch(ctx context.Context, previousRevision string) (groups []apidiscoveryv2beta1.APIGroupDiscovery, revision string, unchanged bool, err error) {
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil).WithContext(ctx)
+	req.Header.Set("Accept", runtime.ContentTypeJSON+";g=apidiscovery.k8s.io;v=v2beta1;as=APIGroupDiscoveryList")
+	if previousRevision != "" {
+		req.Header.Set("If-None-Match", previousRevision)
+	}
+	w := httptest.NewRecorder()
+	p.handler.ServeHTTP(w, req)
+
+	if w.Code == http.StatusNotModified {
+		return nil, previousRevision, true, nil
+	}
+	if w.Code != http.StatusOK {
+		return nil, "", false, fmt.Errorf("peer discovery fetch returned status %d", w.Code)
+	}
+
+	var doc apidiscoveryv2beta1.APIGroupDiscoveryList
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		return nil, "", false, fmt.Errorf("decoding peer discovery document: %w", err)
+	}
+
+	return doc.Items, w.Header().Get("ETag"), false, nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2beta1 "k8s.io/api/apidiscovery/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+	"k8s.io/kube-aggregator/pkg/apiserver"
+)
+
+// flakyPeerHandler wraps a real peer handler and can be switched to fail
+// every request, simulating a peer aggregator that's become unreachable
+// without tearing down and re-adding it (which would lose the "last known
+// good" state this test needs to exercise).
+type flakyPeerHandler struct {
+	inner   http.Handler
+	failing atomic.Bool
+}
+
+func (h *flakyPeerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.failing.Load() {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.inner.ServeHTTP(w, r)
+}
+
+func peerWithGroup(groupName, version string) discoveryendpoint.ResourceManager {
+	peer := discoveryendpoint.NewResourceManager()
+	peer.SetGroups([]apidiscoveryv2beta1.APIGroupDiscovery{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: groupName},
+			Versions: []apidiscoveryv2beta1.APIVersionDiscovery{
+				{Version: version},
+			},
+		},
+	})
+	return peer
+}
+
+func groupNames(list apidiscoveryv2beta1.APIGroupDiscoveryList) []string {
+	var names []string
+	for _, g := range list.Items {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// TestFederatedPeers shows that the aggregated document serves the union of
+// two peer aggregators' groups, each annotated with its peer of origin.
+func TestFederatedPeers(t *testing.T) {
+	aggyService := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggyService)
+
+	peerA := peerWithGroup("a.example.com", "v1")
+	peerB := peerWithGroup("b.example.com", "v1")
+
+	aggregatedManager.AddPeerAggregator("peer-a", peerA, apiserver.PreferPeer)
+	aggregatedManager.AddPeerAggregator("peer-b", peerB, apiserver.PreferPeer)
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+
+	require.Eventually(t, func() bool {
+		names := groupNames(aggyService.List())
+		return len(names) == 2
+	}, 2*time.Second, 10*time.Millisecond, "expected both peers' groups to be merged")
+
+	list := aggyService.List()
+	for _, g := range list.Items {
+		switch g.Name {
+		case "a.example.com":
+			require.Equal(t, "peer-a", g.Annotations["discovery.k8s.io/source"])
+		case "b.example.com":
+			require.Equal(t, "peer-b", g.Annotations["discovery.k8s.io/source"])
+		default:
+			t.Fatalf("unexpected group %s", g.Name)
+		}
+	}
+}
+
+// TestFederatedPeerConflict shows that a conflicting group/version from a
+// second peer is dropped when the first peer already owns it under the
+// Reject policy. See TestFederatedPeerFailureKeepsLastKnownResources for the
+// separate case of a peer's own failure marking its versions stale.
+func TestFederatedPeerConflict(t *testing.T) {
+	aggyService := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggyService)
+
+	peerA := peerWithGroup("shared.example.com", "v1")
+	peerB := peerWithGroup("shared.example.com", "v1")
+
+	aggregatedManager.AddPeerAggregator("peer-a", peerA, apiserver.RejectConflict)
+	aggregatedManager.AddPeerAggregator("peer-b", peerB, apiserver.RejectConflict)
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+
+	require.Eventually(t, func() bool {
+		return len(groupNames(aggyService.List())) == 1
+	}, 2*time.Second, 10*time.Millisecond, "expected exactly one group to win the conflict")
+
+	list := aggyService.List()
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "shared.example.com", list.Items[0].Name)
+	// Whichever peer was processed first keeps ownership; either is a valid
+	// winner, but it must be exactly one of them, and it must carry that
+	// peer's origin annotation.
+	origin := list.Items[0].Annotations["discovery.k8s.io/source"]
+	require.Contains(t, []string{"peer-a", "peer-b"}, origin)
+}
+
+// TestFederatedPeerFailureKeepsLastKnownResources shows that once a peer
+// aggregator starts failing to respond, its previously-merged group/version
+// keeps being served - with its Resources intact and Freshness flagged
+// stale - rather than being wiped down to an empty placeholder or dropped
+// entirely.
+func TestFederatedPeerFailureKeepsLastKnownResources(t *testing.T) {
+	real := discoveryendpoint.NewResourceManager()
+	real.SetGroups([]apidiscoveryv2beta1.APIGroupDiscovery{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "flaky.example.com"},
+			Versions: []apidiscoveryv2beta1.APIVersionDiscovery{
+				{
+					Version: "v1",
+					Resources: []apidiscoveryv2beta1.APIResourceDiscovery{
+						{Resource: "widgets"},
+					},
+				},
+			},
+		},
+	})
+	peer := &flakyPeerHandler{inner: real}
+
+	aggyService := discoveryendpoint.NewResourceManager()
+	aggregatedManager := apiserver.NewDiscoveryManager(aggyService)
+	aggregatedManager.AddPeerAggregator("peer-flaky", peer, apiserver.PreferPeer)
+
+	testCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go aggregatedManager.Run(testCtx.Done())
+
+	require.Eventually(t, func() bool {
+		list := aggyService.List()
+		return len(list.Items) == 1 && len(list.Items[0].Versions) == 1 && len(list.Items[0].Versions[0].Resources) == 1
+	}, 2*time.Second, 10*time.Millisecond, "expected the peer's group/version/resources to be merged before it starts failing")
+
+	peer.failing.Store(true)
+
+	require.Eventually(t, func() bool {
+		list := aggyService.List()
+		if len(list.Items) != 1 || len(list.Items[0].Versions) != 1 {
+			return false
+		}
+		return list.Items[0].Versions[0].Freshness == apidiscoveryv2beta1.DiscoveryFreshnessStale
+	}, 2*time.Second, 10*time.Millisecond, "expected the peer's version to be marked stale once it starts failing")
+
+	list := aggyService.List()
+	require.Len(t, list.Items, 1, "a failing peer's group should keep being served, not disappear")
+	require.Len(t, list.Items[0].Versions, 1)
+	require.Equal(t, []apidiscoveryv2beta1.APIResourceDiscovery{{Resource: "widgets"}}, list.Items[0].Versions[0].Resources,
+		"a failing peer's previously-merged Resources should be preserved, not wiped out")
+}
@@ -419,6 +419,349 @@ func TestEvictionWithFinalizers(t *testing.T) {
 	}
 }
 
+// TestEvictionCoordinatorLeaderElection exercises the opt-in leader-elected
+// eviction coordinator: with the EvictionCoordinator feature gate enabled,
+// starting two DisruptionController instances against the same cluster must
+// result in exactly one of them acquiring the coordinator lease.
+func TestEvictionCoordinatorLeaderElection(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.DefaultFeatureGate, features.EvictionCoordinator, true)()
+
+	closeFn, rm1, informers1, config, clientSet := rmSetup(t)
+	defer closeFn()
+
+	rm2, informers2 := newDisruptionControllerForConfig(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informers1.Start(ctx.Done())
+	informers2.Start(ctx.Done())
+	go rm1.Run(ctx)
+	go rm2.Run(ctx)
+
+	err := wait.PollImmediate(2*time.Second, 30*time.Second, func() (bool, error) {
+		lease, err := clientSet.CoordinationV1().Leases("kube-system").Get(ctx, "disruption-controller-eviction-coordinator", metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "", nil
+	})
+	if err != nil {
+		t.Fatalf("expected a single eviction coordinator lease holder to emerge: %v", err)
+	}
+}
+
+// TestEvictionCoordinatorGrantFunnelsUnderLoad exercises DisruptionController.Grant
+// under concurrent load on whichever of two instances is currently leading the
+// eviction coordinator: firing far more concurrent Grant calls than the PDB's
+// DisruptionsAllowed must never over-grant, and (since every call lands on
+// the same leading instance rather than racing across processes) must never
+// see a 409 Conflict from a RetryOnConflict failure.
+func TestEvictionCoordinatorGrantFunnelsUnderLoad(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.DefaultFeatureGate, features.EvictionCoordinator, true)()
+
+	closeFn, rm1, informers1, config, clientSet := rmSetup(t)
+	defer closeFn()
+
+	rm2, informers2 := newDisruptionControllerForConfig(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informers1.Start(ctx.Done())
+	informers2.Start(ctx.Done())
+	go rm1.Run(ctx)
+	go rm2.Run(ctx)
+
+	err := wait.PollImmediate(2*time.Second, 30*time.Second, func() (bool, error) {
+		lease, err := clientSet.CoordinationV1().Leases("kube-system").Get(ctx, "disruption-controller-eviction-coordinator", metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "", nil
+	})
+	if err != nil {
+		t.Fatalf("expected a single eviction coordinator lease holder to emerge: %v", err)
+	}
+
+	// Grant calls are driven through rm1 regardless of which instance holds
+	// the lease: when rm1 is leading it serves them itself via its grant
+	// queue, and when rm2 is leading, rm1's queue is nil and Grant falls
+	// back to reserving directly - either way every call in this test lands
+	// on a single process, so none of them should conflict with each other.
+	ns := framework.CreateNamespaceOrDie(clientSet, "eviction-coordinator-grant-load", t)
+	defer framework.DeleteNamespaceOrDie(clientSet, ns, t)
+
+	const allowed = 3
+	const concurrentCallers = 50
+
+	pdb := newPDB()
+	pdb.Spec.MinAvailable = &intstr.IntOrString{Type: intstr.Int, IntVal: 0}
+	if _, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Create(ctx, pdb, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create PodDisruptionBudget: %v", err)
+	}
+	waitPDBStable(t, clientSet, 0, ns.Name, pdb.Name)
+
+	// waitPDBStable expects the PDB to be backed by matching pods; here we
+	// only care about DisruptionsAllowed, so set it directly once the
+	// controller has finished its initial sync.
+	current, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod disruption budget: %v", err)
+	}
+	current.Status.DisruptionsAllowed = allowed
+	if _, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).UpdateStatus(ctx, current, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to seed DisruptionsAllowed: %v", err)
+	}
+
+	var totalGranted int32
+	var conflicts int32
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentCallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			granted, err := rm1.Grant(ns.Name, pdb.Name, 1)
+			if apierrors.IsConflict(err) {
+				atomic.AddInt32(&conflicts, 1)
+				return
+			}
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&totalGranted, granted)
+		}()
+	}
+	wg.Wait()
+
+	if conflicts > 0 {
+		t.Errorf("expected Grant calls funneled through the leader to never surface a 409 Conflict, got %d", conflicts)
+	}
+	if totalGranted != allowed {
+		t.Errorf("expected exactly %d of %d concurrent Grant calls to be admitted, got %d", allowed, concurrentCallers, totalGranted)
+	}
+}
+
+// TestBatchEvictionReservation exercises the DisruptionController's
+// budget-reservation primitive that backs the evictions:batch subresource:
+// reserving numOfEvictions disruptions against a single PDB in one call
+// must succeed without ever seeing a 429, must leave DisruptionsAllowed at
+// zero, and must not bump the PDB's spec generation.
+func TestBatchEvictionReservation(t *testing.T) {
+	closeFn, rm, informers, _, clientSet := rmSetup(t)
+	defer closeFn()
+
+	ns := framework.CreateNamespaceOrDie(clientSet, "batch-eviction-reservation", t)
+	defer framework.DeleteNamespaceOrDie(clientSet, ns, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informers.Start(ctx.Done())
+	go rm.Run(ctx)
+
+	for i := 0; i < numOfEvictions; i++ {
+		podName := fmt.Sprintf("batch-pod-%d", i)
+		pod := newPod(podName)
+		if _, err := clientSet.CoreV1().Pods(ns.Name).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+			t.Errorf("Failed to create pod: %v", err)
+		}
+		pod.Status.Phase = v1.PodRunning
+		addPodConditionReady(pod)
+		if _, err := clientSet.CoreV1().Pods(ns.Name).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitToObservePods(t, informers.Core().V1().Pods().Informer(), numOfEvictions, v1.PodRunning)
+
+	pdb := newPDB()
+	pdb.Spec.MinAvailable = &intstr.IntOrString{Type: intstr.Int, IntVal: 0}
+	if _, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Create(ctx, pdb, metav1.CreateOptions{}); err != nil {
+		t.Errorf("Failed to create PodDisruptionBudget: %v", err)
+	}
+	waitPDBStable(t, clientSet, numOfEvictions, ns.Name, pdb.Name)
+
+	oldPdb, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod disruption budget: %v", err)
+	}
+
+	if err := rm.ReserveDisruptions(ns.Name, pdb.Name, numOfEvictions); err != nil {
+		t.Fatalf("expected a single reservation of %d disruptions to succeed, got: %v", numOfEvictions, err)
+	}
+
+	newPdb, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod disruption budget: %v", err)
+	}
+	if newPdb.Status.DisruptionsAllowed != 0 {
+		t.Errorf("expected all %d disruptions to be reserved, %d remain", numOfEvictions, newPdb.Status.DisruptionsAllowed)
+	}
+	if newPdb.Generation != oldPdb.Generation {
+		t.Errorf("reserving disruptions should not bump PDB spec generation")
+	}
+
+	if err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Delete(ctx, pdb.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete pod disruption budget")
+	}
+}
+
+// TestEvictionReasonPropagation ensures a Reason/Message supplied on the
+// Eviction object via annotations is propagated into the pod's
+// DisruptionTarget condition.
+func TestEvictionReasonPropagation(t *testing.T) {
+	closeFn, rm, informers, _, clientSet := rmSetup(t)
+	defer closeFn()
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.DefaultFeatureGate, features.PodDisruptionConditions, true)()
+
+	ns := framework.CreateNamespaceOrDie(clientSet, "eviction-reason", t)
+	defer framework.DeleteNamespaceOrDie(clientSet, ns, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informers.Start(ctx.Done())
+	go rm.Run(ctx)
+
+	pod := newPod("pod-with-reason")
+	if _, err := clientSet.CoreV1().Pods(ns.Name).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Errorf("Failed to create pod: %v", err)
+	}
+	pod.Status.Phase = v1.PodRunning
+	addPodConditionReady(pod)
+	if _, err := clientSet.CoreV1().Pods(ns.Name).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitToObservePods(t, informers.Core().V1().Pods().Informer(), 1, v1.PodRunning)
+
+	eviction := newV1Eviction(ns.Name, pod.Name, metav1.DeleteOptions{})
+	eviction.Annotations = map[string]string{
+		"eviction.k8s.io/reason":  "NodeDrain",
+		"eviction.k8s.io/message": "draining node for upgrade",
+	}
+	err := wait.PollImmediate(5*time.Second, 60*time.Second, func() (bool, error) {
+		e := clientSet.PolicyV1().Evictions(ns.Name).Evict(ctx, eviction)
+		switch {
+		case apierrors.IsTooManyRequests(e):
+			return false, nil
+		case e == nil:
+			return true, nil
+		default:
+			return false, e
+		}
+	})
+	if err != nil {
+		t.Fatalf("Eviction of pod failed: %v", err)
+	}
+
+	updatedPod, err := clientSet.CoreV1().Pods(ns.Name).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the pod: %v", err)
+	}
+	_, cond := podutil.GetPodCondition(&updatedPod.Status, v1.DisruptionTarget)
+	if cond == nil {
+		t.Fatalf("Pod %q does not have the expected DisruptionTarget condition", klog.KObj(updatedPod))
+	}
+	if cond.Reason != "NodeDrain" || cond.Message != "draining node for upgrade" {
+		t.Errorf("unexpected DisruptionTarget condition: reason=%q message=%q", cond.Reason, cond.Message)
+	}
+}
+
+// TestEvictionPreventAnnotation ensures pods carrying the break-glass
+// pod.kubernetes.io/prevent-eviction annotation are rejected by the
+// eviction subresource (dry-run or not) without consuming PDB budget, and
+// that evicting the same pod succeeds once the annotation is removed.
+func TestEvictionPreventAnnotation(t *testing.T) {
+	closeFn, rm, informers, _, clientSet := rmSetup(t)
+	defer closeFn()
+
+	ns := framework.CreateNamespaceOrDie(clientSet, "eviction-prevent-annotation", t)
+	defer framework.DeleteNamespaceOrDie(clientSet, ns, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informers.Start(ctx.Done())
+	go rm.Run(ctx)
+
+	pod := newPod("protected-pod")
+	pod.Annotations = map[string]string{"pod.kubernetes.io/prevent-eviction": "true"}
+	if _, err := clientSet.CoreV1().Pods(ns.Name).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Errorf("Failed to create pod: %v", err)
+	}
+	pod.Status.Phase = v1.PodRunning
+	addPodConditionReady(pod)
+	if _, err := clientSet.CoreV1().Pods(ns.Name).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitToObservePods(t, informers.Core().V1().Pods().Informer(), 1, v1.PodRunning)
+
+	pdb := newPDB()
+	if _, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Create(ctx, pdb, metav1.CreateOptions{}); err != nil {
+		t.Errorf("Failed to create PodDisruptionBudget: %v", err)
+	}
+	waitPDBStable(t, clientSet, 1, ns.Name, pdb.Name)
+
+	oldPdb, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod disruption budget: %v", err)
+	}
+
+	eviction := newV1Eviction(ns.Name, pod.Name, metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	if err := clientSet.PolicyV1().Evictions(ns.Name).Evict(ctx, eviction); err == nil {
+		t.Fatal("expected dry-run eviction of an annotated pod to be rejected, got none")
+	} else if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected a 422 Invalid for the annotated pod, got: %v", err)
+	}
+
+	eviction = newV1Eviction(ns.Name, pod.Name, metav1.DeleteOptions{})
+	if err := clientSet.PolicyV1().Evictions(ns.Name).Evict(ctx, eviction); err == nil {
+		t.Fatal("expected eviction of an annotated pod to be rejected, got none")
+	} else if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected a 422 Invalid for the annotated pod, got: %v", err)
+	}
+
+	newPdb, err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pod disruption budget: %v", err)
+	}
+	if newPdb.Status.ObservedGeneration != oldPdb.Status.ObservedGeneration {
+		t.Fatalf("Expected the pdb generation to be unchanged by a refused eviction, got %v want %v", newPdb.Status.ObservedGeneration, oldPdb.Status.ObservedGeneration)
+	}
+
+	// Unsetting the annotation should allow the eviction through.
+	updatedPod, err := clientSet.CoreV1().Pods(ns.Name).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get pod: %v", err)
+	}
+	delete(updatedPod.Annotations, "pod.kubernetes.io/prevent-eviction")
+	if _, err := clientSet.CoreV1().Pods(ns.Name).Update(ctx, updatedPod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to remove prevent-eviction annotation: %v", err)
+	}
+
+	eviction = newV1Eviction(ns.Name, pod.Name, metav1.DeleteOptions{})
+	err = wait.PollImmediate(5*time.Second, 60*time.Second, func() (bool, error) {
+		e := clientSet.PolicyV1().Evictions(ns.Name).Evict(ctx, eviction)
+		switch {
+		case apierrors.IsTooManyRequests(e):
+			return false, nil
+		case e == nil:
+			return true, nil
+		default:
+			return false, e
+		}
+	})
+	if err != nil {
+		t.Fatalf("Eviction of unannotated pod failed: %v", err)
+	}
+
+	if err := clientSet.PolicyV1().PodDisruptionBudgets(ns.Name).Delete(ctx, pdb.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete pod disruption budget")
+	}
+}
+
 func newPod(podName string) *v1.Pod {
 	return &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -512,6 +855,41 @@ func rmSetup(t *testing.T) (kubeapiservertesting.TearDownFunc, *disruption.Disru
 	return server.TearDownFn, rm, informers, config, clientSet
 }
 
+// newDisruptionControllerForConfig starts a second DisruptionController
+// against an already-running apiserver, so tests can exercise behavior that
+// only shows up with multiple controller replicas (e.g. leader election).
+func newDisruptionControllerForConfig(t *testing.T, config *restclient.Config) (*disruption.DisruptionController, informers.SharedInformerFactory) {
+	clientSet, err := clientset.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("Error in create clientset: %v", err)
+	}
+	resyncPeriod := 12 * time.Hour
+	informers := informers.NewSharedInformerFactory(clientSet, resyncPeriod)
+
+	discoveryClient := cacheddiscovery.NewMemCacheClient(clientSet.Discovery())
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+
+	scaleKindResolver := scale.NewDiscoveryScaleKindResolver(clientSet.Discovery())
+	scaleClient, err := scale.NewForConfig(config, mapper, dynamic.LegacyAPIPathResolverFunc, scaleKindResolver)
+	if err != nil {
+		t.Fatalf("Error in create scaleClient: %v", err)
+	}
+
+	rm := disruption.NewDisruptionController(
+		informers.Core().V1().Pods(),
+		informers.Policy().V1().PodDisruptionBudgets(),
+		informers.Core().V1().ReplicationControllers(),
+		informers.Apps().V1().ReplicaSets(),
+		informers.Apps().V1().Deployments(),
+		informers.Apps().V1().StatefulSets(),
+		clientSet,
+		mapper,
+		scaleClient,
+		clientSet.Discovery(),
+	)
+	return rm, informers
+}
+
 // wait for the podInformer to observe the pods. Call this function before
 // running the RS controller to prevent the rc manager from creating new pods
 // rather than adopting the existing ones.